@@ -0,0 +1,35 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/NickRI/multiplexer/collector"
+)
+
+func Breakers(coll collector.Collector) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		statuses := coll.Breakers()
+		if statuses == nil {
+			statuses = []collector.BreakerStatus{}
+		}
+
+		w.Header().Set("Content-Type", mimeJSON+"; charset=utf-8")
+
+		if err := json.NewEncoder(w).Encode(statuses); err != nil {
+			InternalServerError(w, err)
+			return
+		}
+	}
+}
+
+func PoolStats(coll collector.Collector) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", mimeJSON+"; charset=utf-8")
+
+		if err := json.NewEncoder(w).Encode(coll.Stats()); err != nil {
+			InternalServerError(w, err)
+			return
+		}
+	}
+}
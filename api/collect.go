@@ -8,33 +8,60 @@ import (
 	"github.com/NickRI/multiplexer/collector"
 )
 
-func Collect(collector collector.Collector, urls, clim int) func(http.ResponseWriter, *http.Request) {
+func Collect(collector collector.Collector, urls, clim int, client KeyFunc) func(http.ResponseWriter, *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
-		var request []string
-
-		ctx := r.Context()
+		var specs []spec
 
 		defer r.Body.Close()
 
-		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		if err := json.NewDecoder(r.Body).Decode(&specs); err != nil {
 			InternalServerError(w, err)
 			return
 		}
 
-		if len(request) > urls {
+		if len(specs) > urls {
 			BadRequestError(w, errors.New("url list size is too big"))
 			return
 		}
 
-		data, err := collector.Collect(ctx, request, clim)
+		request, err := toRequests(r, specs)
 		if err != nil {
-			InternalServerError(w, err)
+			BadRequestError(w, err)
+			return
+		}
+
+		v, err := parseView(r)
+		if err != nil {
+			BadRequestError(w, err)
+			return
+		}
+
+		ctx, err := collectContext(r, client)
+		if err != nil {
+			BadRequestError(w, err)
+			return
+		}
+
+		if mediaType := negotiate(r); mediaType != mimeJSON {
+			results, err := collector.Stream(ctx, request, clim)
+			if err != nil {
+				CollectError(w, err, collector.Stats().MaxWait)
+				return
+			}
+
+			stream(w, r, mediaType, v, results)
+			return
+		}
+
+		data, err := collector.CollectPartial(ctx, request, clim)
+		if err != nil {
+			CollectError(w, err, collector.Stats().MaxWait)
 			return
 		}
 
-		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.Header().Set("Content-Type", mimeJSON+"; charset=utf-8")
 
-		if err := json.NewEncoder(w).Encode(data); err != nil {
+		if err := json.NewEncoder(w).Encode(v.renderAll(data)); err != nil {
 			InternalServerError(w, err)
 			return
 		}
@@ -1,8 +1,13 @@
 package api
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/NickRI/multiplexer/collector"
 )
 
 func InternalServerError(w http.ResponseWriter, err error) {
@@ -13,6 +18,23 @@ func BadRequestError(w http.ResponseWriter, err error) {
 	writeErrorRequest(w, http.StatusBadRequest, err)
 }
 
+// CollectError tells the client to come back later if the pool is saturated,
+// the queue frees its place in maxWait at the latest, a second is suggested if it's unbounded
+func CollectError(w http.ResponseWriter, err error, maxWait time.Duration) {
+	switch {
+	case errors.Is(err, collector.ErrQueueFull), errors.Is(err, collector.ErrPoolFull):
+		if maxWait <= 0 {
+			maxWait = time.Second
+		}
+		w.Header().Set("Retry-After", strconv.Itoa(seconds(maxWait)))
+		writeErrorRequest(w, http.StatusServiceUnavailable, err)
+	case errors.Is(err, collector.ErrPoolStopped):
+		writeErrorRequest(w, http.StatusServiceUnavailable, err)
+	default:
+		InternalServerError(w, err)
+	}
+}
+
 func writeErrorRequest(w http.ResponseWriter, code int, err error) {
 	w.WriteHeader(code)
 	fmt.Fprintf(w, "%s: %s", http.StatusText(code), err.Error())
@@ -0,0 +1,58 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/NickRI/multiplexer/collector"
+)
+
+func TestCollectError(t *testing.T) {
+	tests := []struct {
+		name           string
+		err            error
+		maxWait        time.Duration
+		wantCode       int
+		wantRetryAfter string
+	}{
+		{
+			name:           "full queue",
+			err:            collector.ErrQueueFull,
+			maxWait:        2500 * time.Millisecond,
+			wantCode:       http.StatusServiceUnavailable,
+			wantRetryAfter: "3",
+		},
+		{
+			name:           "full pool without queue",
+			err:            fmt.Errorf("collect: %w", collector.ErrPoolFull),
+			wantCode:       http.StatusServiceUnavailable,
+			wantRetryAfter: "1",
+		},
+		{
+			name:     "stopped pool",
+			err:      collector.ErrPoolStopped,
+			maxWait:  time.Minute,
+			wantCode: http.StatusServiceUnavailable,
+		},
+		{
+			name:     "other error",
+			err:      errors.New("broken"),
+			maxWait:  time.Minute,
+			wantCode: http.StatusInternalServerError,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			CollectError(w, tt.err, tt.maxWait)
+
+			if w.Code != tt.wantCode || w.Header().Get("Retry-After") != tt.wantRetryAfter {
+				t.Errorf("CollectError() status = %d, Retry-After = %q, want %d and %q", w.Code, w.Header().Get("Retry-After"), tt.wantCode, tt.wantRetryAfter)
+			}
+		})
+	}
+}
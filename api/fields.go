@@ -0,0 +1,161 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/NickRI/multiplexer/collector"
+)
+
+var resultFields = map[string]func(r collector.Result) interface{}{
+	"Index":       func(r collector.Result) interface{} { return r.Index },
+	"Url":         func(r collector.Result) interface{} { return r.Url },
+	"Body":        body,
+	"Encoding":    func(r collector.Result) interface{} { return r.Encoding },
+	"Charset":     func(r collector.Result) interface{} { return r.Charset },
+	"Status":      func(r collector.Result) interface{} { return r.Status },
+	"Error":       func(r collector.Result) interface{} { return r.Error },
+	"StatusCode":  func(r collector.Result) interface{} { return r.StatusCode },
+	"ContentType": func(r collector.Result) interface{} { return r.ContentType },
+	"Size":        func(r collector.Result) interface{} { return r.Size },
+	"TTFB":        func(r collector.Result) interface{} { return milliseconds(r.TTFB) },
+	"Duration":    func(r collector.Result) interface{} { return milliseconds(r.Duration) },
+	"Attempts":    func(r collector.Result) interface{} { return r.Attempts },
+	"Cache":       func(r collector.Result) interface{} { return r.Cache },
+	"Shared":      func(r collector.Result) interface{} { return r.Shared },
+	"Hedged":      func(r collector.Result) interface{} { return r.Hedged },
+	"BodyLimit":   func(r collector.Result) interface{} { return r.BodyLimit },
+	"Hash":        func(r collector.Result) interface{} { return r.Hash },
+}
+
+var defaultFields = []string{"Index", "Url", "Body", "Encoding", "Status", "Error"}
+
+// body embeds json upstream documents as is instead of escaped string
+func body(r collector.Result) interface{} {
+	if r.Encoding == collector.EncodingJSON {
+		return json.RawMessage(r.Body)
+	}
+	return r.Body
+}
+
+// view is a client choice of result fields and response headers, e.g. ?fields=Url,StatusCode&headers=ETag
+type view struct {
+	fields  []string
+	headers []string
+}
+
+func parseView(r *http.Request) (view, error) {
+	var v = view{fields: defaultFields}
+
+	if fields := r.URL.Query().Get("fields"); fields != "" {
+		v.fields = nil
+
+		for _, field := range strings.Split(fields, ",") {
+			name, ok := fieldName(strings.TrimSpace(field))
+			if !ok {
+				return v, fmt.Errorf("unknown result field %q", field)
+			}
+			v.fields = appendUnique(v.fields, name)
+		}
+	}
+
+	if headers := r.URL.Query().Get("headers"); headers != "" {
+		for _, header := range strings.Split(headers, ",") {
+			v.headers = appendUnique(v.headers, http.CanonicalHeaderKey(strings.TrimSpace(header)))
+		}
+	}
+
+	return v, nil
+}
+
+// appendUnique skips repeated names, a json object can't have duplicate keys
+func appendUnique(names []string, name string) []string {
+	for _, n := range names {
+		if n == name {
+			return names
+		}
+	}
+	return append(names, name)
+}
+
+func fieldName(name string) (string, bool) {
+	for field := range resultFields {
+		if strings.EqualFold(field, name) {
+			return field, true
+		}
+	}
+	return "", false
+}
+
+func (v view) render(r collector.Result) record {
+	var rec = make(record, 0, len(v.fields)+1)
+
+	for _, field := range v.fields {
+		if field == "Error" && r.Error == "" {
+			continue
+		}
+		rec = append(rec, recordField{field, resultFields[field](r)})
+	}
+
+	if len(v.headers) > 0 {
+		var header = make(map[string]string, len(v.headers))
+		for _, key := range v.headers {
+			if value := r.Header.Get(key); value != "" {
+				header[key] = value
+			}
+		}
+		rec = append(rec, recordField{"Header", header})
+	}
+
+	return rec
+}
+
+func (v view) renderAll(rs []collector.Result) []record {
+	var recs = make([]record, len(rs))
+
+	for i, r := range rs {
+		recs[i] = v.render(r)
+	}
+
+	return recs
+}
+
+type recordField struct {
+	name  string
+	value interface{}
+}
+
+// record is a json object which keeps the order of the chosen fields
+type record []recordField
+
+func (rec record) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+
+	buf.WriteByte('{')
+	for i, f := range rec {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+
+		key, _ := json.Marshal(f.name)
+		value, err := json.Marshal(f.value)
+		if err != nil {
+			return nil, err
+		}
+
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+
+	return buf.Bytes(), nil
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
@@ -0,0 +1,115 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/NickRI/multiplexer/collector"
+)
+
+func Test_parseView(t *testing.T) {
+	tests := []struct {
+		name    string
+		query   string
+		want    view
+		wantErr bool
+	}{
+		{
+			name:  "default fields",
+			query: "",
+			want:  view{fields: defaultFields},
+		},
+		{
+			name:  "chosen fields in order",
+			query: "?fields=StatusCode,Url",
+			want:  view{fields: []string{"StatusCode", "Url"}},
+		},
+		{
+			name:  "case-insensitive names",
+			query: "?fields=url,%20statuscode,TTFB",
+			want:  view{fields: []string{"Url", "StatusCode", "TTFB"}},
+		},
+		{
+			name:  "repeated names",
+			query: "?fields=url,Url,body&headers=etag,ETag",
+			want:  view{fields: []string{"Url", "Body"}, headers: []string{"Etag"}},
+		},
+		{
+			name:  "headers with default fields",
+			query: "?headers=content-type,%20x-request-id",
+			want:  view{fields: defaultFields, headers: []string{"Content-Type", "X-Request-Id"}},
+		},
+		{
+			name:    "unknown field",
+			query:   "?fields=Url,Secret",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseView(httptest.NewRequest(http.MethodPost, "/collect"+tt.query, nil))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("parseView() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseView() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_view_render(t *testing.T) {
+	result := collector.Result{
+		Index:      1,
+		Url:        "http://a.com",
+		Body:       `{"a":1}`,
+		Encoding:   collector.EncodingJSON,
+		Status:     collector.StatusOK,
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Etag": {`"v1"`}},
+	}
+
+	failed := result
+	failed.Body, failed.Encoding, failed.Status, failed.Error = "", "", collector.StatusError, "http://a.com :timeout"
+
+	tests := []struct {
+		name   string
+		view   view
+		result collector.Result
+		want   string
+	}{
+		{
+			name:   "default fields without error",
+			view:   view{fields: defaultFields},
+			result: result,
+			want:   `{"Index":1,"Url":"http://a.com","Body":{"a":1},"Encoding":"json","Status":"ok"}`,
+		},
+		{
+			name:   "default fields with error",
+			view:   view{fields: defaultFields},
+			result: failed,
+			want:   `{"Index":1,"Url":"http://a.com","Body":"","Encoding":"","Status":"error","Error":"http://a.com :timeout"}`,
+		},
+		{
+			name:   "chosen fields and headers",
+			view:   view{fields: []string{"StatusCode", "Url"}, headers: []string{"Etag", "X-Missing"}},
+			result: result,
+			want:   `{"StatusCode":200,"Url":"http://a.com","Header":{"Etag":"\"v1\""}}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := json.Marshal(tt.view.render(tt.result))
+			if err != nil {
+				t.Fatalf("json.Marshal() error = %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("render() got = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -1,9 +1,16 @@
 package api
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
+	"io/ioutil"
 	"log"
+	"math"
+	"net"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/NickRI/multiplexer/limit"
 )
@@ -18,12 +25,132 @@ func LoggerMiddleware(next http.Handler) http.Handler {
 func RateLimitMiddleware(limiter limit.Limiter) func(next http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			if !limiter.Allow() {
-				w.Header().Set("Retry-After", "1")
-				w.WriteHeader(http.StatusTooManyRequests)
-				fmt.Fprintln(w, "Too many requests")
+			if !admit(w, limiter, 1) {
+				return
 			}
 			next.ServeHTTP(w, r)
 		})
 	}
 }
+
+// KeyFunc extracts the key which requests are limited by
+type KeyFunc func(r *http.Request) string
+
+// ClientIP keys requests by ip of the client
+func ClientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return "ip:" + r.RemoteAddr
+	}
+	return "ip:" + host
+}
+
+// APIKey keys requests by api key of the header if it's one of the known keys, other requests are keyed by ip,
+// so a client can't get a fresh quota by making up new keys
+func APIKey(header string, keys ...string) KeyFunc {
+	var known = make(map[string]bool, len(keys))
+	for _, key := range keys {
+		known[key] = true
+	}
+
+	return func(r *http.Request) string {
+		if key := r.Header.Get(header); known[key] {
+			return "key:" + key
+		}
+		return ClientIP(r)
+	}
+}
+
+// KeyedRateLimitMiddleware limits requests of each key separately, so a noisy client doesn't take the budget of others
+func KeyedRateLimitMiddleware(limiter limit.KeyedLimiter, key KeyFunc) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !admit(w, limiter.Limiter(key(r)), 1) {
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// maxCollectBody is the size of collect request body which is read ahead to cost it
+const maxCollectBody = 1 << 20
+
+// CostFunc tells how many units of the limiter the request takes
+type CostFunc func(r *http.Request) int
+
+// URLCount costs the collect request by the number of its urls, at most max,
+// the body is read ahead and put back for the handler, malformed bodies cost 1 and are rejected by the handler,
+// bodies over maxCollectBody are cut, so they are malformed as well
+func URLCount(max int) CostFunc {
+	return func(r *http.Request) int {
+		body, err := ioutil.ReadAll(http.MaxBytesReader(nil, r.Body, maxCollectBody))
+		r.Body.Close()
+		r.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+		var specs []json.RawMessage
+		if err != nil || json.Unmarshal(body, &specs) != nil || len(specs) == 0 {
+			return 1
+		}
+
+		if len(specs) > max {
+			return max
+		}
+		return len(specs)
+	}
+}
+
+// WeightedRateLimitMiddleware charges the cost of the request against the limiter,
+// so the budget of outbound fetches is kept apart from the budget of requests,
+// units taken by outer limiters of the chain aren't given back if this one rejects the request
+func WeightedRateLimitMiddleware(limiter limit.Limiter, cost CostFunc) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !admit(w, limiter, cost(r)) {
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// admit takes n units of the request from the quota of the limiter and tells the client about the quota in headers,
+// rejected request is told when all of its n units are admitted
+func admit(w http.ResponseWriter, limiter limit.Limiter, n int) bool {
+	delay, allowed := limiter.ReserveN(n, 0)
+
+	rateLimitHeaders(w.Header(), limiter.State())
+
+	if !allowed {
+		retryAfter := seconds(delay)
+		if retryAfter < 1 {
+			retryAfter = 1
+		}
+
+		w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+		w.WriteHeader(http.StatusTooManyRequests)
+		fmt.Fprintln(w, "Too many requests")
+	}
+
+	return allowed
+}
+
+// rateLimitHeaders sets headers of IETF draft and legacy ones, the tighter quota of other limiter isn't overwritten
+func rateLimitHeaders(h http.Header, state limit.State) {
+	if remaining, err := strconv.Atoi(h.Get("RateLimit-Remaining")); err == nil && remaining < state.Remaining {
+		return
+	}
+
+	h.Set("RateLimit-Limit", strconv.Itoa(state.Limit))
+	h.Set("RateLimit-Remaining", strconv.Itoa(state.Remaining))
+	h.Set("RateLimit-Reset", strconv.Itoa(seconds(state.Reset)))
+
+	// legacy reset is unix time of the moment
+	h.Set("X-RateLimit-Limit", strconv.Itoa(state.Limit))
+	h.Set("X-RateLimit-Remaining", strconv.Itoa(state.Remaining))
+	h.Set("X-RateLimit-Reset", strconv.FormatInt(time.Now().Add(state.Reset).Add(time.Second-1).Unix(), 10))
+}
+
+func seconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
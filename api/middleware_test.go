@@ -0,0 +1,272 @@
+package api
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/NickRI/multiplexer/limit"
+)
+
+func urls(n int) string {
+	var list = make([]string, n)
+	for i := range list {
+		list[i] = `"http://example.com"`
+	}
+	return "[" + strings.Join(list, ",") + "]"
+}
+
+func TestAPIKey(t *testing.T) {
+	tests := []struct {
+		name string
+		key  string
+		want string
+	}{
+		{
+			name: "known key",
+			key:  "alice",
+			want: "key:alice",
+		},
+		{
+			name: "made up key",
+			key:  "mallory",
+			want: "ip:192.0.2.1",
+		},
+		{
+			name: "no key",
+			want: "ip:192.0.2.1",
+		},
+	}
+
+	key := APIKey(APIKeyHeader, "alice", "bob")
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/collect", nil)
+			if tt.key != "" {
+				r.Header.Set(APIKeyHeader, tt.key)
+			}
+
+			if got := key(r); got != tt.want {
+				t.Errorf("APIKey() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestURLCount(t *testing.T) {
+	tooLarge := `["http://example.com/` + strings.Repeat("a", maxCollectBody) + `"]`
+
+	tests := []struct {
+		name     string
+		body     string
+		want     int
+		wantBody string // for the handler, it's the body if empty
+	}{
+		{
+			name: "urls",
+			body: urls(3),
+			want: 3,
+		},
+		{
+			name: "url objects",
+			body: `["http://a.com", {"url": "http://b.com", "method": "POST"}]`,
+			want: 2,
+		},
+		{
+			name: "urls over max",
+			body: urls(30),
+			want: 20,
+		},
+		{
+			name: "empty list",
+			body: `[]`,
+			want: 1,
+		},
+		{
+			name: "malformed body",
+			body: `{"url":`,
+			want: 1,
+		},
+		{
+			name:     "body over the size",
+			body:     tooLarge,
+			want:     1,
+			wantBody: tooLarge[:maxCollectBody],
+		},
+	}
+
+	cost := URLCount(20)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/collect", strings.NewReader(tt.body))
+
+			if got := cost(r); got != tt.want {
+				t.Errorf("URLCount() = %d, want %d", got, tt.want)
+			}
+
+			want := tt.wantBody
+			if want == "" {
+				want = tt.body
+			}
+
+			body, err := ioutil.ReadAll(r.Body)
+			if err != nil || string(body) != want {
+				t.Errorf("body for the handler = %.100q, %v, want %.100q", body, err, want)
+			}
+		})
+	}
+}
+
+func TestWeightedRateLimitMiddleware(t *testing.T) {
+	// a token of the budget of 20 urls per minute is refilled each 3 seconds
+	limiter := limit.NewTokenBucket(time.Minute, 20, 0)
+
+	var handled int
+	handler := WeightedRateLimitMiddleware(limiter, URLCount(20))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var specs []json.RawMessage
+		if err := json.NewDecoder(r.Body).Decode(&specs); err != nil {
+			t.Errorf("handler can't decode the body: %v", err)
+		}
+		handled = len(specs)
+	}))
+
+	// requests go one after another against the same budget
+	tests := []struct {
+		name           string
+		urls           int
+		wantStatus     int
+		wantHandled    int
+		wantRemaining  string
+		wantRetryAfter string
+	}{
+		{
+			name:          "request takes a unit per url",
+			urls:          15,
+			wantStatus:    http.StatusOK,
+			wantHandled:   15,
+			wantRemaining: "5",
+		},
+		{
+			name:           "request over the remaining budget waits for all of its urls",
+			urls:           10,
+			wantStatus:     http.StatusTooManyRequests,
+			wantRemaining:  "5",
+			wantRetryAfter: "15",
+		},
+		{
+			name:          "request within the remaining budget",
+			urls:          5,
+			wantStatus:    http.StatusOK,
+			wantHandled:   5,
+			wantRemaining: "0",
+		},
+		{
+			name:           "single url waits for a single unit",
+			urls:           1,
+			wantStatus:     http.StatusTooManyRequests,
+			wantRemaining:  "0",
+			wantRetryAfter: "3",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handled = 0
+
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/collect", strings.NewReader(urls(tt.urls))))
+
+			if w.Code != tt.wantStatus || handled != tt.wantHandled {
+				t.Errorf("status = %d, handled urls = %d, want %d and %d", w.Code, handled, tt.wantStatus, tt.wantHandled)
+			}
+			if got := w.Header().Get("RateLimit-Remaining"); got != tt.wantRemaining {
+				t.Errorf("RateLimit-Remaining = %q, want %q", got, tt.wantRemaining)
+			}
+			if got := w.Header().Get("Retry-After"); got != tt.wantRetryAfter {
+				t.Errorf("Retry-After = %q, want %q", got, tt.wantRetryAfter)
+			}
+		})
+	}
+}
+
+func Test_rateLimitHeaders(t *testing.T) {
+	tests := []struct {
+		name      string
+		states    []limit.State // of limiters in order of the chain
+		want      http.Header   // without X-RateLimit-Reset
+		wantReset time.Duration // of X-RateLimit-Reset from now
+	}{
+		{
+			name:   "single limiter",
+			states: []limit.State{{Limit: 10, Remaining: 4, Reset: time.Millisecond * 1500}},
+			want: http.Header{
+				"Ratelimit-Limit":       {"10"},
+				"Ratelimit-Remaining":   {"4"},
+				"Ratelimit-Reset":       {"2"},
+				"X-Ratelimit-Limit":     {"10"},
+				"X-Ratelimit-Remaining": {"4"},
+			},
+			wantReset: time.Millisecond * 1500,
+		},
+		{
+			name: "tighter quota isn't overwritten",
+			states: []limit.State{
+				{Limit: 10, Remaining: 2, Reset: time.Second},
+				{Limit: 100, Remaining: 50, Reset: time.Minute},
+			},
+			want: http.Header{
+				"Ratelimit-Limit":       {"10"},
+				"Ratelimit-Remaining":   {"2"},
+				"Ratelimit-Reset":       {"1"},
+				"X-Ratelimit-Limit":     {"10"},
+				"X-Ratelimit-Remaining": {"2"},
+			},
+			wantReset: time.Second,
+		},
+		{
+			name: "looser quota is overwritten",
+			states: []limit.State{
+				{Limit: 100, Remaining: 50, Reset: time.Minute},
+				{Limit: 10, Remaining: 0, Reset: time.Second * 3},
+			},
+			want: http.Header{
+				"Ratelimit-Limit":       {"10"},
+				"Ratelimit-Remaining":   {"0"},
+				"Ratelimit-Reset":       {"3"},
+				"X-Ratelimit-Limit":     {"10"},
+				"X-Ratelimit-Remaining": {"0"},
+			},
+			wantReset: time.Second * 3,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var h = make(http.Header)
+
+			before := time.Now().Add(tt.wantReset).Add(time.Second - 1).Unix()
+			for _, state := range tt.states {
+				rateLimitHeaders(h, state)
+			}
+			after := time.Now().Add(tt.wantReset).Add(time.Second - 1).Unix()
+
+			// legacy reset is unix time, so it's checked apart from the rest
+			reset, err := strconv.ParseInt(h.Get("X-RateLimit-Reset"), 10, 64)
+			if err != nil || reset < before || reset > after {
+				t.Errorf("X-RateLimit-Reset = %q, want between %d and %d", h.Get("X-RateLimit-Reset"), before, after)
+			}
+			h.Del("X-RateLimit-Reset")
+
+			if !reflect.DeepEqual(h, tt.want) {
+				t.Errorf("rateLimitHeaders() = %v, want %v", h, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,139 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/NickRI/multiplexer/collector"
+)
+
+const (
+	priorityHeader = "X-Priority"
+	APIKeyHeader   = "X-API-Key"
+)
+
+// spec is a single url of the collect payload, it's either a plain url string
+// or an object with method, headers and body of the outgoing request
+type spec collector.Request
+
+func (s *spec) UnmarshalJSON(data []byte) error {
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte(`"`)) {
+		return json.Unmarshal(data, &s.URL)
+	}
+
+	var obj struct {
+		URL     string            `json:"url"`
+		Method  string            `json:"method"`
+		Headers map[string]string `json:"headers"`
+		Body    json.RawMessage   `json:"body"`
+
+		MaxBodySize int64                `json:"max_body_size"`
+		BodyPolicy  collector.BodyPolicy `json:"body_policy"`
+		MaxAttempts int                  `json:"max_attempts"`
+		Cache       collector.CacheMode  `json:"cache"`
+	}
+
+	if err := json.Unmarshal(data, &obj); err != nil {
+		return err
+	}
+
+	if obj.URL == "" {
+		return errors.New("url is required")
+	}
+
+	if obj.BodyPolicy != "" && !obj.BodyPolicy.Valid() {
+		return fmt.Errorf("unknown body policy %q", obj.BodyPolicy)
+	}
+
+	if obj.Cache != "" && !obj.Cache.Valid() {
+		return fmt.Errorf("unknown cache mode %q", obj.Cache)
+	}
+
+	s.URL = obj.URL
+	s.Method = obj.Method
+	s.MaxBodySize = obj.MaxBodySize
+	s.BodyPolicy = obj.BodyPolicy
+	s.MaxAttempts = obj.MaxAttempts
+	s.Cache = obj.Cache
+
+	if len(obj.Headers) > 0 {
+		s.Header = make(http.Header, len(obj.Headers))
+		for key, value := range obj.Headers {
+			s.Header.Set(key, value)
+		}
+	}
+
+	// string body is sent as is, any other json value is sent in its raw form
+	if len(obj.Body) > 0 && !bytes.Equal(obj.Body, []byte("null")) {
+		if err := json.Unmarshal(obj.Body, &s.Body); err != nil {
+			s.Body = string(obj.Body)
+		}
+	}
+
+	return nil
+}
+
+// toRequests converts specs to the collector requests,
+// body limits and cache mode of the query are applied to urls which don't have their own
+func toRequests(r *http.Request, specs []spec) ([]collector.Request, error) {
+	var reqs = make([]collector.Request, len(specs))
+
+	var maxBodySize int64
+	if size := r.URL.Query().Get("max_body_size"); size != "" {
+		var err error
+		if maxBodySize, err = strconv.ParseInt(size, 10, 64); err != nil {
+			return nil, fmt.Errorf("wrong max_body_size: %w", err)
+		}
+	}
+
+	bodyPolicy := collector.BodyPolicy(r.URL.Query().Get("body_policy"))
+	if bodyPolicy != "" && !bodyPolicy.Valid() {
+		return nil, fmt.Errorf("unknown body policy %q", bodyPolicy)
+	}
+
+	cacheMode := collector.CacheMode(r.URL.Query().Get("cache"))
+	if cacheMode != "" && !cacheMode.Valid() {
+		return nil, fmt.Errorf("unknown cache mode %q", cacheMode)
+	}
+
+	for i, s := range specs {
+		reqs[i] = collector.Request(s)
+
+		if reqs[i].MaxBodySize == 0 {
+			reqs[i].MaxBodySize = maxBodySize
+		}
+
+		if reqs[i].BodyPolicy == "" {
+			reqs[i].BodyPolicy = bodyPolicy
+		}
+
+		if reqs[i].Cache == "" {
+			reqs[i].Cache = cacheMode
+		}
+	}
+
+	return reqs, nil
+}
+
+// collectContext tags the collection with the client and the priority of the request,
+// collections are interactive by default
+func collectContext(r *http.Request, client KeyFunc) (context.Context, error) {
+	ctx := collector.WithClient(r.Context(), client(r))
+
+	priority := collector.Priority(strings.ToLower(r.Header.Get(priorityHeader)))
+	if priority == "" {
+		return ctx, nil
+	}
+
+	if !priority.Valid() {
+		return nil, fmt.Errorf("unknown priority %q", priority)
+	}
+
+	return collector.WithPriority(ctx, priority), nil
+}
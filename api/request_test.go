@@ -0,0 +1,70 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func Test_spec_UnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		want    []spec
+		wantErr bool
+	}{
+		{
+			name: "plain urls",
+			data: `["http://a.com", "http://b.com"]`,
+			want: []spec{{URL: "http://a.com"}, {URL: "http://b.com"}},
+		},
+		{
+			name: "mixed urls and objects",
+			data: `["http://a.com", {"url": "http://b.com", "method": "POST", "headers": {"x-token": "t"}, "body": "text"}]`,
+			want: []spec{
+				{URL: "http://a.com"},
+				{URL: "http://b.com", Method: "POST", Header: http.Header{"X-Token": {"t"}}, Body: "text"},
+			},
+		},
+		{
+			name: "json body is sent in raw form",
+			data: `[{"url": "http://a.com", "body": {"a": [1, 2]}}]`,
+			want: []spec{{URL: "http://a.com", Body: `{"a": [1, 2]}`}},
+		},
+		{
+			name: "null body",
+			data: `[{"url": "http://a.com", "body": null}]`,
+			want: []spec{{URL: "http://a.com"}},
+		},
+		{
+			name:    "missing url",
+			data:    `["http://a.com", {"method": "POST"}]`,
+			wantErr: true,
+		},
+		{
+			name:    "unknown body policy",
+			data:    `[{"url": "http://a.com", "body_policy": "drop"}]`,
+			wantErr: true,
+		},
+		{
+			name:    "neither url nor object",
+			data:    `[42]`,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []spec
+
+			err := json.Unmarshal([]byte(tt.data), &got)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Unmarshal() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Unmarshal() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
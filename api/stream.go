@@ -0,0 +1,102 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/NickRI/multiplexer/collector"
+	"github.com/NickRI/multiplexer/transport"
+)
+
+const (
+	mimeJSON   = "application/json"
+	mimeNDJSON = "application/x-ndjson"
+	mimeSSE    = "text/event-stream"
+)
+
+type summary struct {
+	Total  int
+	Ok     int
+	Failed int
+}
+
+// negotiate picks the first streaming media type from the Accept header, otherwise plain json
+func negotiate(r *http.Request) string {
+	for _, part := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+
+		switch mediaType {
+		case mimeNDJSON, mimeSSE:
+			return mediaType
+		}
+	}
+
+	return mimeJSON
+}
+
+// stream flushes each result as soon as a worker delivers it and finishes with a summary record
+func stream(w http.ResponseWriter, r *http.Request, mediaType string, v view, results <-chan collector.Result) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		InternalServerError(w, fmt.Errorf("streaming of %s is not supported", mediaType))
+		return
+	}
+
+	w.Header().Set("Content-Type", mediaType+"; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+
+	var sum summary
+
+	write := func(event string, v interface{}) error {
+		if err := transport.ExtendWriteDeadline(r); err != nil {
+			log.Printf("stream: %s", err)
+		}
+
+		bts, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+
+		if mediaType == mimeSSE {
+			_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, bts)
+		} else {
+			_, err = fmt.Fprintf(w, "%s\n", bts)
+		}
+
+		flusher.Flush()
+		return err
+	}
+
+	for result := range results {
+		sum.Total++
+		if result.Status == collector.StatusOK {
+			sum.Ok++
+		} else {
+			sum.Failed++
+		}
+
+		if err := write("result", v.render(result)); err != nil {
+			log.Printf("stream: %s", err)
+			return
+		}
+	}
+
+	var err error
+	if mediaType == mimeSSE {
+		err = write("summary", sum)
+	} else {
+		err = write("summary", struct{ Summary summary }{sum})
+	}
+
+	if err != nil {
+		log.Printf("stream: %s", err)
+	}
+}
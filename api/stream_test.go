@@ -0,0 +1,95 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/NickRI/multiplexer/collector"
+)
+
+func TestCollect_stream(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "some_text")
+	}))
+	defer ts.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// a single worker keeps results in order of urls
+	coll := collector.NewCollector(1, 0, time.Second)
+	coll.Start(ctx)
+
+	const (
+		ok     = `{"Index":0,"Body":"some_text","Status":"ok"}`
+		failed = `{"Index":1,"Body":"","Status":"error","Error":"ftp://example.com/file :unsupported url scheme"}`
+		sum    = `{"Total":2,"Ok":1,"Failed":1}`
+	)
+
+	tests := []struct {
+		name            string
+		accept          string
+		wantContentType string
+		wantBody        string
+	}{
+		{
+			name:            "plain json by default",
+			wantContentType: "application/json; charset=utf-8",
+			wantBody:        "[" + ok + "," + failed + "]\n",
+		},
+		{
+			name:            "plain json",
+			accept:          "application/json",
+			wantContentType: "application/json; charset=utf-8",
+			wantBody:        "[" + ok + "," + failed + "]\n",
+		},
+		{
+			name:            "ndjson",
+			accept:          "application/x-ndjson",
+			wantContentType: "application/x-ndjson; charset=utf-8",
+			wantBody:        ok + "\n" + failed + "\n" + `{"Summary":` + sum + "}\n",
+		},
+		{
+			name:            "server-sent events",
+			accept:          "text/event-stream",
+			wantContentType: "text/event-stream; charset=utf-8",
+			wantBody: "event: result\ndata: " + ok + "\n\n" +
+				"event: result\ndata: " + failed + "\n\n" +
+				"event: summary\ndata: " + sum + "\n\n",
+		},
+		{
+			name:            "first streaming type of the list",
+			accept:          "text/html, application/x-ndjson;q=0.5, text/event-stream",
+			wantContentType: "application/x-ndjson; charset=utf-8",
+			wantBody:        ok + "\n" + failed + "\n" + `{"Summary":` + sum + "}\n",
+		},
+	}
+
+	handler := http.HandlerFunc(Collect(coll, 20, 1, ClientIP))
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := fmt.Sprintf(`[%q, "ftp://example.com/file"]`, ts.URL)
+
+			r := httptest.NewRequest(http.MethodPost, "/collect?fields=Index,Body,Status,Error", strings.NewReader(body))
+			if tt.accept != "" {
+				r.Header.Set("Accept", tt.accept)
+			}
+
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+
+			if w.Code != http.StatusOK || w.Header().Get("Content-Type") != tt.wantContentType {
+				t.Errorf("Collect() status = %d, content type = %q, want %d and %q", w.Code, w.Header().Get("Content-Type"), http.StatusOK, tt.wantContentType)
+			}
+			if got := w.Body.String(); got != tt.wantBody {
+				t.Errorf("Collect() body = %q, want %q", got, tt.wantBody)
+			}
+		})
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
@@ -20,45 +21,142 @@ import (
 )
 
 const (
-	incomingLimit        = 100         // number of incoming requests that can be processed in second
-	outgoingLimit        = 4           // number of outbound requests per second per collection
-	maxCountOfUrls       = 20          // maximum number of incoming urls
-	maxCollectionTmt     = time.Second // timeout per each resource collection
+	incomingLimit        = 100              // number of incoming requests that can be processed in second
+	clientLimit          = 20               // number of incoming requests of each client in second
+	clientKeysCount      = 10000            // number of clients which are tracked by rate limiter
+	clientIdleTmt        = time.Minute      // idle time after which client is forgotten by rate limiter
+	outgoingLimit        = 4                // number of outbound requests per second per collection
+	maxCountOfUrls       = 20               // maximum number of incoming urls
+	maxCollectionTmt     = time.Second      // timeout per each resource collection
+	maxBodySize          = 1 << 20          // maximum size of each collected resource body
+	maxFetchAttempts     = 3                // attempts to collect the resource on transient failures
+	maxHostConnections   = 50               // number of concurrent requests to the same host by all collections
+	maxCacheSize         = 64 << 20         // size of in-memory or on-disk cache of collected responses
+	maxQueuedCollections = incomingLimit    // number of collections waiting for workers of the full pool
+	maxQueueWait         = time.Second      // time which collection can wait for workers
+	shutdownTmt          = time.Second * 10 // time for in-flight collections to finish on shutdown
+	workerIdleTmt        = time.Second * 30 // idle time after which workers above minimum are reaped
 	fixedWorkersCount    = incomingLimit * outgoingLimit
+	minWorkersCount      = fixedWorkersCount / 4
+	reservedWorkersCount = fixedWorkersCount / 4 // workers which batch collections never take
+	fetchLimit           = fixedWorkersCount     // number of urls of incoming requests that can be fetched in second
 	overflowWorkersCount = fixedWorkersCount*(maxCountOfUrls/outgoingLimit) - fixedWorkersCount
 )
 
 func main() {
 	address := flag.String("address", ":8080", "listen server address")
+	cacheDir := flag.String("cache-dir", "", "directory of on-disk response cache, in-memory cache is used if empty")
+	algorithm := flag.String("limiter", string(limit.SlidingWindow), "rate limiting algorithm: sliding-window, token-bucket or gcra")
+	burst := flag.Int("limiter-burst", 0, "requests in a burst of each client for token-bucket and gcra, client limit if zero")
+	apiKeys := flag.String("api-keys", "", "comma separated api keys of X-API-Key header, clients are told apart by ip if empty")
 
 	flag.Parse()
 
+	cache := collector.NewMemoryCache(maxCacheSize)
+	if *cacheDir != "" {
+		var err error
+		if cache, err = collector.NewDiskCache(*cacheDir, maxCacheSize); err != nil {
+			log.Fatal("cache: ", err)
+		}
+	}
+
+	incoming, err := limit.New(limit.Config{Algorithm: limit.Algorithm(*algorithm), Rate: time.Second, Limit: incomingLimit})
+	if err != nil {
+		log.Fatal("limiter: ", err)
+	}
+
+	fetches, err := limit.New(limit.Config{Algorithm: limit.Algorithm(*algorithm), Rate: time.Second, Limit: fetchLimit})
+	if err != nil {
+		log.Fatal("fetch limiter: ", err)
+	}
+
+	var clientConfig = limit.Config{Algorithm: limit.Algorithm(*algorithm), Rate: time.Second, Limit: clientLimit, Burst: *burst}
+	if _, err := limit.New(clientConfig); err != nil {
+		log.Fatal("client limiter: ", err)
+	}
+
+	clients := limit.NewKeyedLimiter(func() limit.Limiter {
+		l, _ := limit.New(clientConfig) // the config is checked above
+		return l
+	}, clientIdleTmt, clientKeysCount)
+
+	var clientKey api.KeyFunc = api.ClientIP
+	if *apiKeys != "" {
+		clientKey = api.APIKey(api.APIKeyHeader, strings.Split(*apiKeys, ",")...)
+	}
+
 	ctx, cancel := context.WithCancel(context.Background())
 
-	coll := collector.NewCollector(fixedWorkersCount, overflowWorkersCount, maxCollectionTmt)
+	coll := collector.NewCollector(fixedWorkersCount, overflowWorkersCount, maxCollectionTmt,
+		collector.WithMinWorkers(minWorkersCount),
+		collector.WithIdleTimeout(workerIdleTmt, time.Millisecond*100),
+		collector.WithWaitQueue(maxQueuedCollections, maxQueueWait),
+		collector.WithPriorityReserve(reservedWorkersCount),
+		collector.WithMaxBodySize(maxBodySize, collector.BodyTruncate),
+		collector.WithRetryPolicy(collector.RetryPolicy{
+			MaxAttempts: maxFetchAttempts,
+			BaseDelay:   time.Millisecond * 100,
+			MaxDelay:    time.Second,
+			Jitter:      0.5,
+		}),
+		collector.WithHostLimit("*", maxHostConnections),
+		collector.WithCircuitBreaker(collector.BreakerPolicy{}),
+		collector.WithCache(cache),
+		collector.WithCoalescing(),
+		collector.WithHedging(collector.HedgePolicy{
+			Percentile: 0.95,
+			MinDelay:   time.Millisecond * 100,
+			Budget:     0.05,
+		}),
+	)
 	coll.Start(ctx)
 
 	srv := transport.NewServer(*address)
 
 	srv.Post("/collect",
-		http.HandlerFunc(api.Collect(coll, maxCountOfUrls, outgoingLimit)),
-		api.RateLimitMiddleware(limit.NewLimiter(time.Second, incomingLimit)),
+		http.HandlerFunc(api.Collect(coll, maxCountOfUrls, outgoingLimit, clientKey)),
+		// noisy client is stopped before it takes the global budget
+		api.KeyedRateLimitMiddleware(clients, clientKey),
+		api.RateLimitMiddleware(incoming),
+		// collection of many urls takes more of the fetch budget than a single url
+		api.WeightedRateLimitMiddleware(fetches, api.URLCount(maxCountOfUrls)),
+		api.LoggerMiddleware,
+	)
+
+	srv.Get("/admin/breakers",
+		http.HandlerFunc(api.Breakers(coll)),
+		api.LoggerMiddleware,
+	)
+
+	srv.Get("/debug/pool",
+		http.HandlerFunc(api.PoolStats(coll)),
 		api.LoggerMiddleware,
 	)
 
 	c := make(chan os.Signal, 1)
-	signal.Notify(c, syscall.SIGINT)
+	signal.Notify(c, syscall.SIGINT, syscall.SIGTERM)
+
+	stopped := make(chan struct{})
 
 	go func() {
-		for range c {
-			log.Println("Shutting down server...")
+		defer close(stopped)
+
+		<-c
+		log.Println("Shutting down server...")
 
-			if err := srv.Shutdown(context.Background()); err != nil {
-				log.Fatal(err)
-			}
+		shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), shutdownTmt)
+		defer shutdownCancel()
 
-			cancel()
+		if err := srv.Shutdown(shutdownCtx); err != nil {
+			log.Printf("server shutdown: %v", err)
 		}
+
+		// collections which outlived the deadline are canceled
+		if err := coll.Shutdown(shutdownCtx); err != nil {
+			log.Printf("collector shutdown: %v", err)
+		}
+
+		cancel()
 	}()
 
 	log.Printf("limiter server starting on %s", *address)
@@ -66,4 +164,6 @@ func main() {
 		log.Fatal("server: ", err)
 	}
 
+	// server stops accepting at once, but in-flight collections are drained
+	<-stopped
 }
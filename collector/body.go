@@ -0,0 +1,170 @@
+package collector
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"mime"
+	"net/http"
+	"regexp"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+const (
+	EncodingText   = "text"   // body is utf-8 text
+	EncodingJSON   = "json"   // body is valid utf-8 json document
+	EncodingBase64 = "base64" // body is base64 encoded bytes as they came from upstream
+)
+
+// windows-1252 differs from latin-1 only in 0x80-0x9f range, undefined bytes are kept as is
+var cp1252 = [32]rune{
+	0x20ac, 0x0081, 0x201a, 0x0192, 0x201e, 0x2026, 0x2020, 0x2021,
+	0x02c6, 0x2030, 0x0160, 0x2039, 0x0152, 0x008d, 0x017d, 0x008f,
+	0x0090, 0x2018, 0x2019, 0x201c, 0x201d, 0x2022, 0x2013, 0x2014,
+	0x02dc, 0x2122, 0x0161, 0x203a, 0x0153, 0x009d, 0x017e, 0x0178,
+}
+
+var metaCharset = regexp.MustCompile(`(?i)<meta[^>]+charset\s*=\s*["']?\s*([a-z0-9_:.-]+)`)
+
+// decodeBody turns raw upstream bytes into a json safe string,
+// text is transcoded to utf-8 and everything else is base64 encoded
+func decodeBody(contentType string, bts []byte) (body, encoding, charset string) {
+	if contentType == "" {
+		contentType = http.DetectContentType(bts)
+	}
+
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mediaType, params = "application/octet-stream", nil
+	}
+
+	if !isText(mediaType) {
+		return base64.StdEncoding.EncodeToString(bts), EncodingBase64, ""
+	}
+
+	charset = strings.ToLower(params["charset"])
+	if charset == "" {
+		charset = sniffCharset(bts)
+	}
+
+	text, ok := transcode(charset, bts)
+	if !ok {
+		return base64.StdEncoding.EncodeToString(bts), EncodingBase64, charset
+	}
+
+	if isJSON(mediaType) && json.Valid([]byte(text)) {
+		return text, EncodingJSON, charset
+	}
+
+	return text, EncodingText, charset
+}
+
+func isJSON(mediaType string) bool {
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
+
+func isText(mediaType string) bool {
+	switch {
+	case strings.HasPrefix(mediaType, "text/"), isJSON(mediaType), strings.HasSuffix(mediaType, "+xml"):
+		return true
+	}
+
+	switch mediaType {
+	case "application/xml", "application/javascript", "application/ecmascript", "application/x-www-form-urlencoded":
+		return true
+	}
+
+	return false
+}
+
+func sniffCharset(bts []byte) string {
+	switch {
+	case bytes.HasPrefix(bts, []byte{0xef, 0xbb, 0xbf}):
+		return "utf-8"
+	case bytes.HasPrefix(bts, []byte{0xfe, 0xff}):
+		return "utf-16be"
+	case bytes.HasPrefix(bts, []byte{0xff, 0xfe}):
+		return "utf-16le"
+	}
+
+	head := bts
+	if len(head) > 1024 {
+		head = head[:1024]
+	}
+
+	if m := metaCharset.FindSubmatch(head); m != nil {
+		return strings.ToLower(string(m[1]))
+	}
+
+	if utf8.Valid(bts) {
+		return "utf-8"
+	}
+
+	return "windows-1252"
+}
+
+func transcode(charset string, bts []byte) (string, bool) {
+	switch charset {
+	case "utf-8", "utf8", "unicode-1-1-utf-8":
+		bts = trimPartialRune(bytes.TrimPrefix(bts, []byte{0xef, 0xbb, 0xbf}))
+		return string(bts), utf8.Valid(bts)
+	case "us-ascii", "ascii", "iso-8859-1", "iso8859-1", "iso_8859-1", "latin1", "l1", "windows-1252", "cp1252", "x-cp1252":
+		// browsers treat all of them as windows-1252, so do we
+		return decodeCP1252(bts), true
+	case "utf-16", "utf-16le":
+		return decodeUTF16(bts, binary.LittleEndian), true
+	case "utf-16be":
+		return decodeUTF16(bts, binary.BigEndian), true
+	}
+
+	return "", false
+}
+
+// trimPartialRune cuts the incomplete utf-8 sequence off the end of truncated body
+func trimPartialRune(bts []byte) []byte {
+	for i := len(bts) - 1; i >= 0 && i >= len(bts)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(bts[i]) {
+			if !utf8.FullRune(bts[i:]) {
+				return bts[:i]
+			}
+			break
+		}
+	}
+	return bts
+}
+
+func decodeCP1252(bts []byte) string {
+	var sb strings.Builder
+	sb.Grow(len(bts))
+
+	for _, b := range bts {
+		switch {
+		case b >= 0x80 && b <= 0x9f:
+			sb.WriteRune(cp1252[b-0x80])
+		default:
+			sb.WriteRune(rune(b))
+		}
+	}
+
+	return sb.String()
+}
+
+func decodeUTF16(bts []byte, order binary.ByteOrder) string {
+	switch {
+	case bytes.HasPrefix(bts, []byte{0xfe, 0xff}):
+		bts, order = bts[2:], binary.BigEndian
+	case bytes.HasPrefix(bts, []byte{0xff, 0xfe}):
+		bts, order = bts[2:], binary.LittleEndian
+	}
+
+	// odd tail byte is a leftover of the truncated body
+	var units = make([]uint16, len(bts)/2)
+	for i := range units {
+		units[i] = order.Uint16(bts[i*2:])
+	}
+
+	return string(utf16.Decode(units))
+}
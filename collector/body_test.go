@@ -0,0 +1,109 @@
+package collector
+
+import "testing"
+
+func Test_decodeBody(t *testing.T) {
+	tests := []struct {
+		name         string
+		contentType  string
+		bts          []byte
+		wantBody     string
+		wantEncoding string
+		wantCharset  string
+	}{
+		{
+			name:         "utf-8 text",
+			contentType:  "text/plain; charset=utf-8",
+			bts:          []byte("привет"),
+			wantBody:     "привет",
+			wantEncoding: EncodingText,
+			wantCharset:  "utf-8",
+		},
+		{
+			name:         "truncated utf-8 text",
+			contentType:  "text/plain; charset=utf-8",
+			bts:          []byte("привет")[:5],
+			wantBody:     "пр",
+			wantEncoding: EncodingText,
+			wantCharset:  "utf-8",
+		},
+		{
+			name:         "latin-1 text",
+			contentType:  "text/html; charset=ISO-8859-1",
+			bts:          []byte{'c', 'a', 'f', 0xe9},
+			wantBody:     "café",
+			wantEncoding: EncodingText,
+			wantCharset:  "iso-8859-1",
+		},
+		{
+			name:         "windows-1252 quotes",
+			contentType:  "text/plain; charset=windows-1252",
+			bts:          []byte{0x93, 'q', 0x94, ' ', 0x80},
+			wantBody:     "“q” €",
+			wantEncoding: EncodingText,
+			wantCharset:  "windows-1252",
+		},
+		{
+			name:         "utf-16 with bom",
+			contentType:  "text/plain; charset=utf-16",
+			bts:          []byte{0xfe, 0xff, 0x00, 'h', 0x00, 'i'},
+			wantBody:     "hi",
+			wantEncoding: EncodingText,
+			wantCharset:  "utf-16",
+		},
+		{
+			name:         "charset from html meta",
+			contentType:  "text/html",
+			bts:          []byte(`<html><meta charset="windows-1252"><p>na` + "\xefve"),
+			wantBody:     `<html><meta charset="windows-1252"><p>naïve`,
+			wantEncoding: EncodingText,
+			wantCharset:  "windows-1252",
+		},
+		{
+			name:         "json document",
+			contentType:  "application/json",
+			bts:          []byte(`{"a":[1,2]}`),
+			wantBody:     `{"a":[1,2]}`,
+			wantEncoding: EncodingJSON,
+			wantCharset:  "utf-8",
+		},
+		{
+			name:         "broken json stays text",
+			contentType:  "application/problem+json",
+			bts:          []byte(`{"a":`),
+			wantBody:     `{"a":`,
+			wantEncoding: EncodingText,
+			wantCharset:  "utf-8",
+		},
+		{
+			name:         "binary payload",
+			contentType:  "image/png",
+			bts:          []byte{0x89, 'P', 'N', 'G'},
+			wantBody:     "iVBORw==",
+			wantEncoding: EncodingBase64,
+		},
+		{
+			name:         "sniffed binary payload",
+			bts:          []byte{0x00, 0x01, 0x02, 0xff},
+			wantBody:     "AAEC/w==",
+			wantEncoding: EncodingBase64,
+		},
+		{
+			name:         "unknown charset",
+			contentType:  "text/plain; charset=koi8-r",
+			bts:          []byte{0xf0, 0xd2},
+			wantBody:     "8NI=",
+			wantEncoding: EncodingBase64,
+			wantCharset:  "koi8-r",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body, encoding, charset := decodeBody(tt.contentType, tt.bts)
+			if body != tt.wantBody || encoding != tt.wantEncoding || charset != tt.wantCharset {
+				t.Errorf("decodeBody() = %q, %q, %q, want %q, %q, %q", body, encoding, charset, tt.wantBody, tt.wantEncoding, tt.wantCharset)
+			}
+		})
+	}
+}
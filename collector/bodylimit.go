@@ -0,0 +1,83 @@
+package collector
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"io/ioutil"
+)
+
+type BodyPolicy string
+
+const (
+	BodyError    BodyPolicy = "error"    // fail the url
+	BodyTruncate BodyPolicy = "truncate" // keep the first allowed bytes of the body
+	BodyHash     BodyPolicy = "hash"     // drop the body and keep only sha256 of it
+)
+
+var ErrBodyTooLarge = errors.New("response body is too large")
+
+func (p BodyPolicy) Valid() bool {
+	switch p {
+	case BodyError, BodyTruncate, BodyHash:
+		return true
+	}
+	return false
+}
+
+// bodyLimit resolves the effective limit of the request against the collector settings
+func (c *collector) bodyLimit(req Request) (int64, BodyPolicy) {
+	var size, policy = c.maxBodySize, c.bodyPolicy
+
+	if req.MaxBodySize > 0 && (size <= 0 || req.MaxBodySize < size) {
+		size = req.MaxBodySize
+	}
+
+	if req.BodyPolicy != "" {
+		policy = req.BodyPolicy
+	}
+
+	if policy == "" {
+		policy = BodyError
+	}
+
+	return size, policy
+}
+
+type body struct {
+	bts      []byte
+	size     int64      // number of read bytes
+	exceeded BodyPolicy // applied policy if body is bigger than limit
+	hash     string
+}
+
+func readBody(r io.Reader, limit int64, policy BodyPolicy) (body, error) {
+	if limit <= 0 {
+		bts, err := ioutil.ReadAll(r)
+		return body{bts: bts, size: int64(len(bts))}, err
+	}
+
+	// one extra byte tells that the body is bigger than limit
+	bts, err := ioutil.ReadAll(io.LimitReader(r, limit+1))
+	if err != nil || int64(len(bts)) <= limit {
+		return body{bts: bts, size: int64(len(bts))}, err
+	}
+
+	switch policy {
+	case BodyTruncate:
+		return body{bts: bts[:limit], size: limit, exceeded: BodyTruncate}, nil
+	case BodyHash:
+		h := sha256.New()
+		h.Write(bts)
+
+		n, err := io.Copy(h, r)
+		if err != nil {
+			return body{}, err
+		}
+
+		return body{size: int64(len(bts)) + n, exceeded: BodyHash, hash: hex.EncodeToString(h.Sum(nil))}, nil
+	default:
+		return body{size: int64(len(bts)), exceeded: BodyError}, ErrBodyTooLarge
+	}
+}
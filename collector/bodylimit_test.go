@@ -0,0 +1,120 @@
+package collector
+
+import (
+	"strings"
+	"testing"
+)
+
+func Test_readBody(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		limit   int64
+		policy  BodyPolicy
+		want    body
+		wantErr bool
+	}{
+		{
+			name: "no limit",
+			data: "0123456789",
+			want: body{bts: []byte("0123456789"), size: 10},
+		},
+		{
+			name:   "within limit",
+			data:   "0123456789",
+			limit:  10,
+			policy: BodyError,
+			want:   body{bts: []byte("0123456789"), size: 10},
+		},
+		{
+			name:    "exceeded with error",
+			data:    "0123456789",
+			limit:   5,
+			policy:  BodyError,
+			want:    body{size: 6, exceeded: BodyError},
+			wantErr: true,
+		},
+		{
+			name:   "exceeded with truncate",
+			data:   "0123456789",
+			limit:  5,
+			policy: BodyTruncate,
+			want:   body{bts: []byte("01234"), size: 5, exceeded: BodyTruncate},
+		},
+		{
+			name:   "exceeded with hash",
+			data:   "0123456789",
+			limit:  5,
+			policy: BodyHash,
+			want:   body{size: 10, exceeded: BodyHash, hash: "84d89877f0d4041efb6bf91a16f0248f2fd573e6af05c19f96bedb9f882f7882"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := readBody(strings.NewReader(tt.data), tt.limit, tt.policy)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("readBody() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if string(got.bts) != string(tt.want.bts) || got.size != tt.want.size || got.exceeded != tt.want.exceeded || got.hash != tt.want.hash {
+				t.Errorf("readBody() got = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_collector_bodyLimit(t *testing.T) {
+	tests := []struct {
+		name       string
+		size       int64
+		policy     BodyPolicy
+		req        Request
+		wantSize   int64
+		wantPolicy BodyPolicy
+	}{
+		{
+			name:       "no limits at all",
+			wantPolicy: BodyError,
+		},
+		{
+			name:       "collector limit",
+			size:       100,
+			policy:     BodyTruncate,
+			wantSize:   100,
+			wantPolicy: BodyTruncate,
+		},
+		{
+			name:       "request lowers the limit and overrides policy",
+			size:       100,
+			policy:     BodyTruncate,
+			req:        Request{MaxBodySize: 10, BodyPolicy: BodyHash},
+			wantSize:   10,
+			wantPolicy: BodyHash,
+		},
+		{
+			name:       "request can't exceed collector limit",
+			size:       100,
+			req:        Request{MaxBodySize: 1000},
+			wantSize:   100,
+			wantPolicy: BodyError,
+		},
+		{
+			name:       "request limit without collector limit",
+			req:        Request{MaxBodySize: 1000},
+			wantSize:   1000,
+			wantPolicy: BodyError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewCollector(1, 0, 0, WithMaxBodySize(tt.size, tt.policy)).(*collector)
+
+			size, policy := c.bodyLimit(tt.req)
+			if size != tt.wantSize || policy != tt.wantPolicy {
+				t.Errorf("bodyLimit() = %v, %v, want %v, %v", size, policy, tt.wantSize, tt.wantPolicy)
+			}
+		})
+	}
+}
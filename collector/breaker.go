@@ -0,0 +1,228 @@
+package collector
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+type BreakerState string
+
+const (
+	BreakerClosed   BreakerState = "closed"    // requests go to upstream, outcomes are counted
+	BreakerOpen     BreakerState = "open"      // requests are short-circuited until cool-down is over
+	BreakerHalfOpen BreakerState = "half-open" // few trial requests decide to close or open the breaker again
+)
+
+type BreakerPolicy struct {
+	Window      time.Duration // period of counting outcomes in closed state, 10s if zero
+	MinRequests int           // minimal number of requests in the window to judge the failure rate, 5 if zero
+	FailureRate float64       // ratio of failed requests which opens the breaker, 0.5 if zero
+	CoolDown    time.Duration // time in open state before trial requests, 5s if zero
+	Probes      int           // number of successful trial requests which close the breaker, 1 if zero
+}
+
+type BreakerStatus struct {
+	Host      string
+	State     BreakerState
+	Requests  int        // requests in the current window
+	Failures  int        // failures in the current window
+	OpenUntil *time.Time `json:",omitempty"` // end of the cool-down of open breaker
+}
+
+// WithCircuitBreaker short-circuits requests to hosts which fail too often
+func WithCircuitBreaker(policy BreakerPolicy) Option {
+	return func(c *collector) {
+		if policy.Window <= 0 {
+			policy.Window = time.Second * 10
+		}
+		if policy.MinRequests <= 0 {
+			policy.MinRequests = 5
+		}
+		if policy.FailureRate <= 0 {
+			policy.FailureRate = 0.5
+		}
+		if policy.CoolDown <= 0 {
+			policy.CoolDown = time.Second * 5
+		}
+		if policy.Probes <= 0 {
+			policy.Probes = 1
+		}
+
+		c.breakers = &breakers{policy: policy, byHost: make(map[string]*breaker)}
+	}
+}
+
+// idleWindows is number of windows without requests after which the breaker of the host is forgotten
+const idleWindows = 3
+
+type breakers struct {
+	sync.Mutex
+	policy  BreakerPolicy
+	byHost  map[string]*breaker
+	sweptAt time.Time
+}
+
+func (bs *breakers) get(host string) *breaker {
+	var t = now()
+
+	bs.Lock()
+	defer bs.Unlock()
+
+	if t.Sub(bs.sweptAt) >= bs.policy.Window {
+		bs.sweep(t)
+	}
+
+	b, ok := bs.byHost[host]
+	if !ok {
+		b = &breaker{policy: bs.policy, state: BreakerClosed, windowStart: t, lastSeen: t}
+		bs.byHost[host] = b
+	}
+
+	return b
+}
+
+// sweep drops breakers of hosts which weren't requested for a while, so every host ever seen isn't kept forever,
+// late outcome of a request which outlived its breaker is lost
+func (bs *breakers) sweep(t time.Time) {
+	bs.sweptAt = t
+
+	for host, b := range bs.byHost {
+		if b.idle(t, bs.policy.Window*idleWindows) {
+			delete(bs.byHost, host)
+		}
+	}
+}
+
+func (bs *breakers) statuses() []BreakerStatus {
+	bs.Lock()
+	var list = make([]BreakerStatus, 0, len(bs.byHost))
+	for host, b := range bs.byHost {
+		list = append(list, b.status(host))
+	}
+	bs.Unlock()
+
+	sort.Slice(list, func(i, j int) bool { return list[i].Host < list[j].Host })
+
+	return list
+}
+
+type breaker struct {
+	sync.Mutex
+	policy BreakerPolicy
+	state  BreakerState
+
+	windowStart time.Time
+	requests    int
+	failures    int
+
+	openedAt  time.Time
+	probes    int // trial requests in flight
+	successes int // successful trial requests
+
+	lastSeen time.Time
+}
+
+func (b *breaker) allow(t time.Time) bool {
+	b.Lock()
+	defer b.Unlock()
+
+	b.lastSeen = t
+
+	switch b.state {
+	case BreakerOpen:
+		if t.Before(b.openedAt.Add(b.policy.CoolDown)) {
+			return false
+		}
+		b.state, b.probes, b.successes = BreakerHalfOpen, 0, 0
+		fallthrough
+	case BreakerHalfOpen:
+		if b.probes+b.successes >= b.policy.Probes {
+			return false
+		}
+		b.probes++
+		return true
+	default:
+		b.renew(t)
+		return true
+	}
+}
+
+func (b *breaker) done(t time.Time, failure bool) {
+	b.Lock()
+	defer b.Unlock()
+
+	b.lastSeen = t
+
+	switch b.state {
+	case BreakerHalfOpen:
+		b.probes--
+		if failure {
+			b.open(t)
+			return
+		}
+		if b.successes++; b.successes >= b.policy.Probes {
+			b.state = BreakerClosed
+			b.windowStart, b.requests, b.failures = t, 0, 0
+		}
+	case BreakerClosed:
+		b.renew(t)
+		b.requests++
+		if failure {
+			b.failures++
+		}
+		if b.requests >= b.policy.MinRequests && float64(b.failures)/float64(b.requests) >= b.policy.FailureRate {
+			b.open(t)
+		}
+	}
+	// late outcomes of requests made before the breaker was opened are ignored
+}
+
+// abort gives back the trial slot of the request which outcome says nothing about upstream
+func (b *breaker) abort() {
+	b.Lock()
+	defer b.Unlock()
+
+	if b.state == BreakerHalfOpen {
+		b.probes--
+	}
+}
+
+// idle reports whether the breaker wasn't used for the period and forgetting it doesn't cut the cool-down short
+func (b *breaker) idle(t time.Time, period time.Duration) bool {
+	b.Lock()
+	defer b.Unlock()
+
+	if b.state == BreakerOpen && t.Before(b.openedAt.Add(b.policy.CoolDown)) || b.probes > 0 {
+		return false
+	}
+
+	return t.Sub(b.lastSeen) >= period
+}
+
+func (b *breaker) renew(t time.Time) {
+	if t.Sub(b.windowStart) >= b.policy.Window {
+		b.windowStart, b.requests, b.failures = t, 0, 0
+	}
+}
+
+func (b *breaker) open(t time.Time) {
+	b.state, b.openedAt = BreakerOpen, t
+	b.requests, b.failures = 0, 0
+}
+
+func (b *breaker) status(host string) BreakerStatus {
+	b.Lock()
+	defer b.Unlock()
+
+	var status = BreakerStatus{Host: host, State: b.state, Requests: b.requests, Failures: b.failures}
+	if b.state == BreakerOpen {
+		until := b.openedAt.Add(b.policy.CoolDown)
+		status.OpenUntil = &until
+	}
+
+	return status
+}
@@ -0,0 +1,191 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func Test_breaker(t *testing.T) {
+	var policy = BreakerPolicy{
+		Window:      time.Second,
+		MinRequests: 4,
+		FailureRate: 0.5,
+		CoolDown:    time.Second * 5,
+		Probes:      2,
+	}
+
+	type step struct {
+		at        time.Duration // offset from the start
+		allow     bool          // call allow, otherwise done
+		failure   bool          // outcome for done
+		wantAllow bool
+		wantState BreakerState
+	}
+
+	tests := []struct {
+		name  string
+		steps []step
+	}{
+		{
+			name: "too few requests to judge",
+			steps: []step{
+				{at: 0, failure: true, wantState: BreakerClosed},
+				{at: 0, failure: true, wantState: BreakerClosed},
+				{at: 0, failure: true, wantState: BreakerClosed},
+				{at: 0, allow: true, wantAllow: true, wantState: BreakerClosed},
+			},
+		},
+		{
+			name: "failure rate opens the breaker",
+			steps: []step{
+				{at: 0, failure: false, wantState: BreakerClosed},
+				{at: 0, failure: true, wantState: BreakerClosed},
+				{at: 0, failure: false, wantState: BreakerClosed},
+				{at: 0, failure: true, wantState: BreakerOpen},
+				{at: time.Second, allow: true, wantAllow: false, wantState: BreakerOpen},
+			},
+		},
+		{
+			name: "window renewal forgets old failures",
+			steps: []step{
+				{at: 0, failure: true, wantState: BreakerClosed},
+				{at: 0, failure: true, wantState: BreakerClosed},
+				{at: 0, failure: true, wantState: BreakerClosed},
+				{at: time.Second, failure: true, wantState: BreakerClosed},
+			},
+		},
+		{
+			name: "half-open after cool-down and closed by probes",
+			steps: []step{
+				{at: 0, failure: true},
+				{at: 0, failure: true},
+				{at: 0, failure: true},
+				{at: 0, failure: true, wantState: BreakerOpen},
+				{at: time.Second * 5, allow: true, wantAllow: true, wantState: BreakerHalfOpen},
+				{at: time.Second * 5, allow: true, wantAllow: true, wantState: BreakerHalfOpen},
+				{at: time.Second * 5, allow: true, wantAllow: false, wantState: BreakerHalfOpen},
+				{at: time.Second * 6, failure: false, wantState: BreakerHalfOpen},
+				{at: time.Second * 6, failure: false, wantState: BreakerClosed},
+				{at: time.Second * 6, allow: true, wantAllow: true, wantState: BreakerClosed},
+			},
+		},
+		{
+			name: "failed probe opens the breaker again",
+			steps: []step{
+				{at: 0, failure: true},
+				{at: 0, failure: true},
+				{at: 0, failure: true},
+				{at: 0, failure: true, wantState: BreakerOpen},
+				{at: time.Second * 5, allow: true, wantAllow: true, wantState: BreakerHalfOpen},
+				{at: time.Second * 6, failure: true, wantState: BreakerOpen},
+				{at: time.Second * 10, allow: true, wantAllow: false, wantState: BreakerOpen},
+				{at: time.Second * 11, allow: true, wantAllow: true, wantState: BreakerHalfOpen},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start := time.Unix(0, 0)
+			b := &breaker{policy: policy, state: BreakerClosed, windowStart: start}
+
+			for i, s := range tt.steps {
+				if s.allow {
+					if got := b.allow(start.Add(s.at)); got != s.wantAllow {
+						t.Errorf("step #%d allow() = %v, want %v", i, got, s.wantAllow)
+					}
+				} else {
+					b.done(start.Add(s.at), s.failure)
+				}
+
+				if s.wantState != "" && b.state != s.wantState {
+					t.Errorf("step #%d state = %v, want %v", i, b.state, s.wantState)
+				}
+			}
+		})
+	}
+}
+
+func Test_breakers_sweep(t *testing.T) {
+	var policy = BreakerPolicy{Window: time.Second, MinRequests: 1, FailureRate: 0.5, CoolDown: time.Second * 5, Probes: 1}
+
+	tests := []struct {
+		name     string
+		failure  bool          // outcome of the only request to the host
+		at       time.Duration // offset of the request to other host
+		wantKept bool
+	}{
+		{
+			name:     "recently used breaker",
+			at:       time.Second * 2,
+			wantKept: true,
+		},
+		{
+			name: "idle closed breaker",
+			at:   time.Second * 3,
+		},
+		{
+			name:     "idle breaker in cool-down",
+			failure:  true,
+			at:       time.Second * 4,
+			wantKept: true,
+		},
+		{
+			name:    "idle breaker after cool-down",
+			failure: true,
+			at:      time.Second * 5,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func() { now = time.Now }()
+
+			start := time.Unix(0, 0)
+			now = func() time.Time { return start }
+
+			bs := &breakers{policy: policy, byHost: make(map[string]*breaker)}
+
+			b := bs.get("a.com")
+			b.allow(start)
+			b.done(start, tt.failure)
+
+			now = func() time.Time { return start.Add(tt.at) }
+			bs.get("b.com")
+
+			if _, ok := bs.byHost["a.com"]; ok != tt.wantKept {
+				t.Errorf("breaker is kept = %v, want %v", ok, tt.wantKept)
+			}
+		})
+	}
+}
+
+func Test_collector_fetch_breaker(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer ts.Close()
+
+	c := NewCollector(1, 0, time.Second, WithCircuitBreaker(BreakerPolicy{MinRequests: 2, CoolDown: time.Minute})).(*collector)
+
+	prm := param{ctx: context.Background(), req: Request{URL: ts.URL}}
+
+	for i := 0; i < 2; i++ {
+		if got := c.fetch(prm); got.StatusCode != http.StatusInternalServerError {
+			t.Fatalf("fetch() status = %d, want %d", got.StatusCode, http.StatusInternalServerError)
+		}
+	}
+
+	if got := c.fetch(prm); !errors.Is(got.Err(), ErrCircuitOpen) {
+		t.Errorf("fetch() error = %v, want %v", got.Err(), ErrCircuitOpen)
+	}
+
+	statuses := c.Breakers()
+	if len(statuses) != 1 || statuses[0].Host != "127.0.0.1" || statuses[0].State != BreakerOpen || statuses[0].OpenUntil == nil {
+		t.Errorf("Breakers() = %+v", statuses)
+	}
+}
@@ -0,0 +1,350 @@
+package collector
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type CacheMode string
+
+const (
+	CachePrefer CacheMode = "prefer" // serve fresh responses from the cache, revalidate stale ones and store new ones
+	CacheBypass CacheMode = "bypass" // don't use the cache at all
+	CacheOnly   CacheMode = "only"   // serve stored responses even stale ones, never go to upstream
+)
+
+func (m CacheMode) Valid() bool {
+	switch m {
+	case CachePrefer, CacheBypass, CacheOnly:
+		return true
+	}
+	return false
+}
+
+type CacheStatus string
+
+const (
+	CacheHit         CacheStatus = "hit"         // fresh stored response
+	CacheStale       CacheStatus = "stale"       // stale stored response, only for CacheOnly mode
+	CacheRevalidated CacheStatus = "revalidated" // stale stored response confirmed by upstream
+	CacheMiss        CacheStatus = "miss"        // response came from upstream
+)
+
+var ErrCacheMiss = errors.New("response is not in the cache")
+
+// CacheEntry is a stored upstream response
+type CacheEntry struct {
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+	Vary       map[string]string // request header values the response varies by
+	Stored     time.Time
+	Expires    time.Time // end of the freshness lifetime
+}
+
+type Cache interface {
+	Get(key string) (*CacheEntry, bool)
+	Set(key string, entry *CacheEntry)
+	Delete(key string)
+}
+
+// WithCache stores upstream responses by http caching rules, requests choose how to use it by CacheMode
+func WithCache(cache Cache) Option {
+	return func(c *collector) {
+		c.cache = cache
+	}
+}
+
+func (r Request) cacheMode() CacheMode {
+	if r.Cache == "" {
+		return CachePrefer
+	}
+	return r.Cache
+}
+
+// cacheable requests are GET ones without their own conditions
+func (r Request) cacheable() bool {
+	if r.method() != http.MethodGet || r.cacheMode() == CacheBypass {
+		return false
+	}
+
+	for _, key := range []string{"If-None-Match", "If-Modified-Since", "If-Match", "If-Unmodified-Since", "If-Range", "Range"} {
+		if r.Header.Get(key) != "" {
+			return false
+		}
+	}
+
+	return true
+}
+
+// cacheKey includes all headers of the request, since clients may send their own cookies or credentials,
+// so a response stored for one client is never served to another one
+func cacheKey(r Request) string {
+	var sb strings.Builder
+	sb.WriteString(r.method() + " " + r.URL)
+	writeHeader(&sb, r.Header)
+
+	return sb.String()
+}
+
+func (e *CacheEntry) matches(r Request) bool {
+	for key, value := range e.Vary {
+		if r.Header.Get(key) != value {
+			return false
+		}
+	}
+	return true
+}
+
+func (e *CacheEntry) fresh(t time.Time) bool {
+	return t.Before(e.Expires)
+}
+
+// lookup finds the stored response which can be served without going to upstream
+func (c *collector) lookup(prm param) (Result, bool) {
+	if c.cache == nil || !prm.req.cacheable() {
+		return Result{}, false
+	}
+
+	entry, ok := c.cache.Get(cacheKey(prm.req))
+	if !ok || !entry.matches(prm.req) {
+		return Result{}, false
+	}
+
+	switch {
+	case entry.fresh(now()):
+		return c.fromCache(prm, entry, CacheHit), true
+	case prm.req.cacheMode() == CacheOnly:
+		return c.fromCache(prm, entry, CacheStale), true
+	}
+
+	return Result{}, false
+}
+
+// fetchCached serves the request from the cache, revalidates stale responses and stores new ones
+func (c *collector) fetchCached(prm param) Result {
+	if result, ok := c.lookup(prm); ok {
+		return result
+	}
+
+	var key = cacheKey(prm.req)
+
+	entry, ok := c.cache.Get(key)
+	if ok && !entry.matches(prm.req) {
+		entry, ok = nil, false
+	}
+
+	if prm.req.cacheMode() == CacheOnly {
+		result := failed(prm, fmt.Errorf("%s :%w", prm.req.URL, ErrCacheMiss))
+		result.Cache = CacheMiss
+		return result
+	}
+
+	var origin = prm.req
+	if ok {
+		prm.req = conditional(prm.req, entry)
+	}
+
+	var requested = now()
+
+	result := c.fetchRetry(prm)
+	prm.req = origin
+
+	if result.err != nil {
+		return result
+	}
+
+	if ok && result.StatusCode == http.StatusNotModified {
+		entry = entry.refresh(result.Header, requested)
+		c.cache.Set(key, entry)
+
+		cached := c.fromCache(prm, entry, CacheRevalidated)
+		cached.TTFB, cached.Duration, cached.Attempts = result.TTFB, result.Duration, result.Attempts
+		return cached
+	}
+
+	result.Cache = CacheMiss
+
+	if entry, store := newCacheEntry(prm.req, result, requested); store {
+		c.cache.Set(key, entry)
+	} else if ok {
+		c.cache.Delete(key)
+	}
+
+	return result
+}
+
+func (c *collector) fromCache(prm param, entry *CacheEntry, status CacheStatus) Result {
+	result := c.response(prm, entry.StatusCode, entry.Header, bytes.NewReader(entry.Body))
+	result.Cache = status
+	result.raw = nil // it's already stored
+	return result
+}
+
+// conditional asks upstream to confirm the stored response by its validators
+func conditional(r Request, entry *CacheEntry) Request {
+	r.Header = r.Header.Clone()
+	if r.Header == nil {
+		r.Header = make(http.Header)
+	}
+
+	if etag := entry.Header.Get("ETag"); etag != "" {
+		r.Header.Set("If-None-Match", etag)
+	}
+
+	if modified := entry.Header.Get("Last-Modified"); modified != "" {
+		r.Header.Set("If-Modified-Since", modified)
+	}
+
+	return r
+}
+
+// refresh updates the stored response by headers of 304 response
+func (e *CacheEntry) refresh(header http.Header, requested time.Time) *CacheEntry {
+	var updated = *e
+
+	updated.Header = e.Header.Clone()
+	for key, values := range header {
+		updated.Header[key] = values
+	}
+
+	lifetime := freshness(updated.Header, requested)
+
+	updated.Stored = requested
+	updated.Expires = requested.Add(lifetime)
+
+	return &updated
+}
+
+func newCacheEntry(r Request, result Result, requested time.Time) (*CacheEntry, bool) {
+	if result.raw == nil || !cacheableStatus(result.StatusCode) {
+		return nil, false
+	}
+
+	cc := parseCacheControl(result.Header.Get("Cache-Control"))
+
+	if _, ok := cc["no-store"]; ok {
+		return nil, false
+	}
+
+	// the collector is a shared cache
+	if _, ok := cc["private"]; ok {
+		return nil, false
+	}
+
+	if r.Header.Get("Authorization") != "" {
+		_, public := cc["public"]
+		_, shared := cc["s-maxage"]
+		if !public && !shared {
+			return nil, false
+		}
+	}
+
+	lifetime := freshness(result.Header, requested)
+
+	// stale responses are worth to store only if they can be revalidated
+	hasValidators := result.Header.Get("ETag") != "" || result.Header.Get("Last-Modified") != ""
+	if lifetime <= 0 && !hasValidators {
+		return nil, false
+	}
+
+	var vary map[string]string
+	for _, value := range result.Header.Values("Vary") {
+		for _, key := range strings.Split(value, ",") {
+			key = http.CanonicalHeaderKey(strings.TrimSpace(key))
+			if key == "*" {
+				return nil, false
+			}
+			if key == "" {
+				continue
+			}
+			if vary == nil {
+				vary = make(map[string]string)
+			}
+			vary[key] = r.Header.Get(key)
+		}
+	}
+
+	return &CacheEntry{
+		StatusCode: result.StatusCode,
+		Header:     result.Header,
+		Body:       result.raw,
+		Vary:       vary,
+		Stored:     requested,
+		Expires:    requested.Add(lifetime),
+	}, true
+}
+
+func cacheableStatus(code int) bool {
+	switch code {
+	case http.StatusOK, http.StatusNonAuthoritativeInfo, http.StatusNoContent, http.StatusMultipleChoices,
+		http.StatusMovedPermanently, http.StatusNotFound, http.StatusGone:
+		return true
+	}
+	return false
+}
+
+// freshness is a lifetime of the response by Cache-Control or Expires minus its current age
+func freshness(header http.Header, requested time.Time) (lifetime time.Duration) {
+	cc := parseCacheControl(header.Get("Cache-Control"))
+
+	if _, ok := cc["no-cache"]; ok {
+		return 0
+	}
+
+	date := requested
+	if d, err := http.ParseTime(header.Get("Date")); err == nil {
+		date = d
+	}
+
+	switch {
+	case cc["s-maxage"] != "":
+		lifetime = seconds(cc["s-maxage"])
+	case cc["max-age"] != "":
+		lifetime = seconds(cc["max-age"])
+	case header.Get("Expires") != "":
+		// malformed Expires means already expired
+		if expires, err := http.ParseTime(header.Get("Expires")); err == nil {
+			lifetime = expires.Sub(date)
+		}
+	}
+
+	if age := header.Get("Age"); age != "" {
+		lifetime -= seconds(age)
+	}
+
+	return lifetime
+}
+
+func parseCacheControl(value string) map[string]string {
+	var cc = make(map[string]string)
+
+	for _, directive := range strings.Split(value, ",") {
+		directive = strings.TrimSpace(directive)
+		if directive == "" {
+			continue
+		}
+
+		name, arg := directive, ""
+		if i := strings.IndexByte(directive, '='); i >= 0 {
+			name, arg = directive[:i], strings.Trim(directive[i+1:], `"`)
+		}
+
+		cc[strings.ToLower(name)] = arg
+	}
+
+	return cc
+}
+
+func seconds(value string) time.Duration {
+	n, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+	if err != nil || n < 0 {
+		return 0
+	}
+	return time.Duration(n) * time.Second
+}
@@ -0,0 +1,172 @@
+package collector
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/gob"
+	"encoding/hex"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const diskTempPrefix = "tmp-"
+
+type diskItem struct {
+	name string
+	size int64
+}
+
+// diskCache keeps each entry in its own gob file named by the key hash,
+// it's LRU cache bounded by the total size of the files like memoryCache
+type diskCache struct {
+	dir string
+
+	sync.Mutex
+	maxBytes int64
+	size     int64
+	order    *list.List // front is the most recently used
+	items    map[string]*list.Element
+}
+
+func NewDiskCache(dir string, maxBytes int64) (Cache, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	d := &diskCache{
+		dir:      dir,
+		maxBytes: maxBytes,
+		order:    list.New(),
+		items:    make(map[string]*list.Element),
+	}
+
+	// entries left by the previous run are used in order of their modification
+	sort.Slice(files, func(i, j int) bool { return files[i].ModTime().Before(files[j].ModTime()) })
+
+	for _, file := range files {
+		if file.IsDir() || strings.HasPrefix(file.Name(), diskTempPrefix) {
+			continue
+		}
+		d.add(file.Name(), file.Size())
+	}
+	d.evict()
+
+	return d, nil
+}
+
+func (d *diskCache) name(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+func (d *diskCache) Get(key string) (*CacheEntry, bool) {
+	name := d.name(key)
+
+	f, err := os.Open(filepath.Join(d.dir, name))
+	if err != nil {
+		return nil, false
+	}
+	defer f.Close()
+
+	var entry CacheEntry
+	if err := gob.NewDecoder(f).Decode(&entry); err != nil {
+		log.Printf("disk cache: broken entry %s: %s", f.Name(), err)
+		return nil, false
+	}
+
+	d.Lock()
+	if el, ok := d.items[name]; ok {
+		d.order.MoveToFront(el)
+	}
+	d.Unlock()
+
+	return &entry, true
+}
+
+func (d *diskCache) Set(key string, entry *CacheEntry) {
+	f, err := ioutil.TempFile(d.dir, diskTempPrefix)
+	if err != nil {
+		log.Printf("disk cache: %s", err)
+		return
+	}
+
+	err = gob.NewEncoder(f).Encode(entry)
+
+	var size int64
+	if err == nil {
+		size, err = f.Seek(0, io.SeekCurrent)
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+
+	if err == nil && size > d.maxBytes {
+		os.Remove(f.Name())
+		return
+	}
+
+	name := d.name(key)
+
+	// rename is atomic, so readers never see partially written entry
+	if err == nil {
+		err = os.Rename(f.Name(), filepath.Join(d.dir, name))
+	}
+
+	if err != nil {
+		log.Printf("disk cache: %s", err)
+		os.Remove(f.Name())
+		return
+	}
+
+	d.Lock()
+	defer d.Unlock()
+
+	if el, ok := d.items[name]; ok {
+		d.forget(el)
+	}
+	d.add(name, size)
+	d.evict()
+}
+
+func (d *diskCache) Delete(key string) {
+	name := d.name(key)
+
+	d.Lock()
+	defer d.Unlock()
+
+	if el, ok := d.items[name]; ok {
+		d.forget(el)
+	}
+	os.Remove(filepath.Join(d.dir, name))
+}
+
+func (d *diskCache) add(name string, size int64) {
+	d.items[name] = d.order.PushFront(&diskItem{name: name, size: size})
+	d.size += size
+}
+
+func (d *diskCache) forget(el *list.Element) {
+	item := d.order.Remove(el).(*diskItem)
+	delete(d.items, item.name)
+	d.size -= item.size
+}
+
+// evict removes the least recently used files until the cache fits its size
+func (d *diskCache) evict() {
+	for d.size > d.maxBytes {
+		el := d.order.Back()
+		os.Remove(filepath.Join(d.dir, el.Value.(*diskItem).name))
+		d.forget(el)
+	}
+}
@@ -0,0 +1,77 @@
+package collector
+
+import (
+	"container/list"
+	"sync"
+)
+
+type memoryItem struct {
+	key   string
+	entry *CacheEntry
+}
+
+// memoryCache is LRU cache bounded by the total size of stored bodies
+type memoryCache struct {
+	sync.Mutex
+	maxBytes int64
+	size     int64
+	order    *list.List // front is the most recently used
+	items    map[string]*list.Element
+}
+
+func NewMemoryCache(maxBytes int64) Cache {
+	return &memoryCache{
+		maxBytes: maxBytes,
+		order:    list.New(),
+		items:    make(map[string]*list.Element),
+	}
+}
+
+func (m *memoryCache) Get(key string) (*CacheEntry, bool) {
+	m.Lock()
+	defer m.Unlock()
+
+	el, ok := m.items[key]
+	if !ok {
+		return nil, false
+	}
+
+	m.order.MoveToFront(el)
+
+	return el.Value.(*memoryItem).entry, true
+}
+
+func (m *memoryCache) Set(key string, entry *CacheEntry) {
+	m.Lock()
+	defer m.Unlock()
+
+	if int64(len(entry.Body)) > m.maxBytes {
+		return
+	}
+
+	if el, ok := m.items[key]; ok {
+		m.remove(el)
+	}
+
+	m.items[key] = m.order.PushFront(&memoryItem{key: key, entry: entry})
+	m.size += int64(len(entry.Body))
+
+	for m.size > m.maxBytes {
+		m.remove(m.order.Back())
+	}
+}
+
+func (m *memoryCache) Delete(key string) {
+	m.Lock()
+	defer m.Unlock()
+
+	if el, ok := m.items[key]; ok {
+		m.remove(el)
+	}
+}
+
+func (m *memoryCache) remove(el *list.Element) {
+	item := m.order.Remove(el).(*memoryItem)
+	delete(m.items, item.key)
+	m.size -= int64(len(item.entry.Body))
+}
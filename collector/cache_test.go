@@ -0,0 +1,318 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func Test_freshness(t *testing.T) {
+	var requested = time.Date(2020, 7, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name   string
+		header http.Header
+		want   time.Duration
+	}{
+		{
+			name:   "no directives",
+			header: http.Header{},
+			want:   0,
+		},
+		{
+			name:   "max-age",
+			header: http.Header{"Cache-Control": {"public, max-age=60"}},
+			want:   time.Minute,
+		},
+		{
+			name:   "s-maxage wins for shared cache",
+			header: http.Header{"Cache-Control": {"max-age=60, s-maxage=120"}},
+			want:   time.Minute * 2,
+		},
+		{
+			name:   "no-cache",
+			header: http.Header{"Cache-Control": {"no-cache, max-age=60"}},
+			want:   0,
+		},
+		{
+			name:   "age is subtracted",
+			header: http.Header{"Cache-Control": {"max-age=60"}, "Age": {"20"}},
+			want:   time.Second * 40,
+		},
+		{
+			name: "expires relative to date",
+			header: http.Header{
+				"Date":    {"Wed, 01 Jul 2020 11:00:00 GMT"},
+				"Expires": {"Wed, 01 Jul 2020 11:05:00 GMT"},
+			},
+			want: time.Minute * 5,
+		},
+		{
+			name:   "malformed expires",
+			header: http.Header{"Expires": {"0"}},
+			want:   0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := freshness(tt.header, requested); got != tt.want {
+				t.Errorf("freshness() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_memoryCache(t *testing.T) {
+	cache := NewMemoryCache(10)
+
+	cache.Set("a", &CacheEntry{Body: []byte("aaaa")})
+	cache.Set("b", &CacheEntry{Body: []byte("bbbb")})
+	cache.Get("a") // b is the least recently used now
+	cache.Set("c", &CacheEntry{Body: []byte("cccc")})
+	cache.Set("huge", &CacheEntry{Body: []byte("more than ten bytes")})
+
+	for key, want := range map[string]bool{"a": true, "b": false, "c": true, "huge": false} {
+		if _, ok := cache.Get(key); ok != want {
+			t.Errorf("Get(%q) found = %v, want %v", key, ok, want)
+		}
+	}
+}
+
+func Test_diskCache(t *testing.T) {
+	dir, err := ioutil.TempDir("", "collector-cache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	cache, err := NewDiskCache(dir, 1<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cache.Set("GET http://example.com", &CacheEntry{StatusCode: http.StatusOK, Body: []byte("some_text")})
+
+	entry, ok := cache.Get("GET http://example.com")
+	if !ok || entry.StatusCode != http.StatusOK || string(entry.Body) != "some_text" {
+		t.Errorf("Get() = %+v, %v", entry, ok)
+	}
+
+	cache.Delete("GET http://example.com")
+
+	if _, ok := cache.Get("GET http://example.com"); ok {
+		t.Errorf("Get() found deleted entry")
+	}
+}
+
+func Test_diskCache_evict(t *testing.T) {
+	dir, err := ioutil.TempDir("", "collector-cache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	cache, err := NewDiskCache(dir, 1<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// entries of the same shape take the same room on disk
+	cache.Set("size", &CacheEntry{Body: []byte("ssss")})
+	size := cache.(*diskCache).size
+	cache.Delete("size")
+
+	if cache, err = NewDiskCache(dir, size*5/2); err != nil {
+		t.Fatal(err)
+	}
+
+	cache.Set("a", &CacheEntry{Body: []byte("aaaa")})
+	cache.Set("b", &CacheEntry{Body: []byte("bbbb")})
+	cache.Get("a") // b is the least recently used now
+	cache.Set("c", &CacheEntry{Body: []byte("cccc")})
+	cache.Set("huge", &CacheEntry{Body: make([]byte, size*3)})
+
+	for key, want := range map[string]bool{"a": true, "b": false, "c": true, "huge": false} {
+		if _, ok := cache.Get(key); ok != want {
+			t.Errorf("Get(%q) found = %v, want %v", key, ok, want)
+		}
+	}
+
+	// the next run keeps the most recently written files which fit its size
+	if cache, err = NewDiskCache(dir, size); err != nil {
+		t.Fatal(err)
+	}
+
+	files, _ := ioutil.ReadDir(dir)
+	if len(files) != 1 {
+		t.Errorf("ReadDir() got %d files, want 1", len(files))
+	}
+}
+
+func Test_collector_Collect_cache(t *testing.T) {
+	var hits int32
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+
+		switch r.URL.Path {
+		case "/fresh":
+			w.Header().Set("Cache-Control", "max-age=60")
+		case "/etag":
+			w.Header().Set("ETag", `"v1"`)
+			if r.Header.Get("If-None-Match") == `"v1"` {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+		case "/no-store":
+			w.Header().Set("Cache-Control", "no-store")
+		}
+
+		fmt.Fprint(w, "some_text")
+	}))
+	defer ts.Close()
+
+	tests := []struct {
+		name      string
+		req       Request
+		wantCache []CacheStatus // outcome of each consecutive collection
+		wantHits  int32
+		wantErr   error
+	}{
+		{
+			name:      "fresh response",
+			req:       Request{URL: ts.URL + "/fresh"},
+			wantCache: []CacheStatus{CacheMiss, CacheHit, CacheHit},
+			wantHits:  1,
+		},
+		{
+			name:      "revalidated by etag",
+			req:       Request{URL: ts.URL + "/etag"},
+			wantCache: []CacheStatus{CacheMiss, CacheRevalidated, CacheRevalidated},
+			wantHits:  3,
+		},
+		{
+			name:      "not stored",
+			req:       Request{URL: ts.URL + "/no-store"},
+			wantCache: []CacheStatus{CacheMiss, CacheMiss},
+			wantHits:  2,
+		},
+		{
+			name:      "bypass",
+			req:       Request{URL: ts.URL + "/fresh", Cache: CacheBypass},
+			wantCache: []CacheStatus{"", ""},
+			wantHits:  2,
+		},
+		{
+			name:      "only cache",
+			req:       Request{URL: ts.URL + "/fresh", Cache: CacheOnly},
+			wantCache: []CacheStatus{CacheMiss},
+			wantErr:   ErrCacheMiss,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			atomic.StoreInt32(&hits, 0)
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			c := NewCollector(1, 0, time.Second, WithCache(NewMemoryCache(1<<20)))
+			c.Start(ctx)
+
+			for i, want := range tt.wantCache {
+				got, err := c.CollectPartial(context.Background(), []Request{tt.req}, 1)
+				if err != nil {
+					t.Fatalf("CollectPartial() error = %v", err)
+				}
+
+				if got[0].Cache != want {
+					t.Errorf("collection #%d cache = %q, want %q", i, got[0].Cache, want)
+				}
+
+				if !errors.Is(got[0].Err(), tt.wantErr) {
+					t.Errorf("collection #%d error = %v, want %v", i, got[0].Err(), tt.wantErr)
+				}
+
+				if tt.wantErr == nil && (got[0].Body != "some_text" || got[0].StatusCode != http.StatusOK) {
+					t.Errorf("collection #%d got %+v", i, got[0])
+				}
+			}
+
+			if got := atomic.LoadInt32(&hits); got != tt.wantHits {
+				t.Errorf("upstream hits = %d, want %d", got, tt.wantHits)
+			}
+		})
+	}
+}
+
+func Test_collector_Collect_cache_clients(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "max-age=60")
+		fmt.Fprint(w, "hello "+r.Header.Get("Cookie"))
+	}))
+	defer ts.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	c := NewCollector(1, 0, time.Second, WithCache(NewMemoryCache(1<<20)))
+	c.Start(ctx)
+
+	tests := []struct {
+		name      string
+		cookie    string
+		wantCache CacheStatus
+		wantBody  string
+	}{
+		{
+			name:      "first client",
+			cookie:    "session=alice",
+			wantCache: CacheMiss,
+			wantBody:  "hello session=alice",
+		},
+		{
+			name:      "anonymous client",
+			wantCache: CacheMiss,
+			wantBody:  "hello ",
+		},
+		{
+			name:      "other client",
+			cookie:    "session=bob",
+			wantCache: CacheMiss,
+			wantBody:  "hello session=bob",
+		},
+		{
+			name:      "first client again",
+			cookie:    "session=alice",
+			wantCache: CacheHit,
+			wantBody:  "hello session=alice",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var req = Request{URL: ts.URL}
+			if tt.cookie != "" {
+				req.Header = http.Header{"Cookie": {tt.cookie}}
+			}
+
+			got, err := c.CollectPartial(context.Background(), []Request{req}, 1)
+			if err != nil {
+				t.Fatalf("CollectPartial() error = %v", err)
+			}
+
+			if got[0].Cache != tt.wantCache || got[0].Body != tt.wantBody {
+				t.Errorf("CollectPartial() cache = %q, body = %q, want %q and %q", got[0].Cache, got[0].Body, tt.wantCache, tt.wantBody)
+			}
+		})
+	}
+}
@@ -0,0 +1,155 @@
+package collector
+
+import (
+	"container/list"
+	"context"
+)
+
+type clientKey struct{}
+
+// WithClient tags collections of the context with the client, clients share workers of the saturated pool fairly,
+// all untagged collections are treated as one client
+func WithClient(ctx context.Context, client string) context.Context {
+	return context.WithValue(ctx, clientKey{}, client)
+}
+
+func clientOf(ctx context.Context) string {
+	client, _ := ctx.Value(clientKey{}).(string)
+	return client
+}
+
+// WithClientWeight gives the client the weighted share of workers of the saturated pool, weight of other clients is 1
+func WithClientWeight(client string, weight int) Option {
+	return func(c *collector) {
+		c.fair.weights[client] = weight
+	}
+}
+
+// fairness takes turns of clients waiting for workers by deficit round robin,
+// each turn the client earns its weight in workers and spends it on its queued collections,
+// queued collections of each client are kept as they come and go, so picking the next one doesn't scan the queue
+type fairness struct {
+	weights  map[string]int
+	clients  map[string]*clientQueue
+	ring     *list.List       // clients with queued collections
+	turn     *list.Element    // the client which takes its turn in the ring
+	credited bool             // the client has earned its weight in the current turn
+	queued   map[Priority]int // queued collections of each class
+}
+
+type clientQueue struct {
+	client  string
+	deficit int
+	waiters map[Priority]*list.List // elements of the wait queue by class in order of arrival
+	ring    *list.Element
+}
+
+func newFairness() fairness {
+	return fairness{
+		weights: make(map[string]int),
+		clients: make(map[string]*clientQueue),
+		ring:    list.New(),
+		queued:  make(map[Priority]int),
+	}
+}
+
+func (f *fairness) quantum(client string) int {
+	if weight, ok := f.weights[client]; ok && weight > 0 {
+		return weight
+	}
+	return 1
+}
+
+// join adds the queued collection to its client, the client joins the ring with the first one
+func (f *fairness) join(elem *list.Element) {
+	w := elem.Value.(*waiter)
+
+	cq, ok := f.clients[w.client]
+	if !ok {
+		cq = &clientQueue{client: w.client, waiters: make(map[Priority]*list.List)}
+		cq.ring = f.ring.PushBack(cq)
+		f.clients[w.client] = cq
+	}
+
+	if cq.waiters[w.priority] == nil {
+		cq.waiters[w.priority] = list.New()
+	}
+
+	w.own = cq.waiters[w.priority].PushBack(elem)
+	f.queued[w.priority]++
+}
+
+// leave removes the collection from its client, the client leaves the ring and loses its deficit with the last one
+func (f *fairness) leave(w *waiter) {
+	cq := f.clients[w.client]
+
+	cq.waiters[w.priority].Remove(w.own)
+	f.queued[w.priority]--
+
+	for _, waiters := range cq.waiters {
+		if waiters.Len() > 0 {
+			return
+		}
+	}
+
+	if f.turn == cq.ring {
+		f.turn, f.credited = cq.ring.Next(), false
+	}
+	f.ring.Remove(cq.ring)
+	delete(f.clients, w.client)
+}
+
+func (f *fairness) charge(w *waiter) {
+	f.clients[w.client].deficit -= w.count
+}
+
+// push queues the collection, it's called under the lock
+func (c *collector) push(w *waiter) *list.Element {
+	elem := c.queue.PushBack(w)
+	c.fair.join(elem)
+	return elem
+}
+
+// remove drops the collection from the queue, it's called under the lock
+func (c *collector) remove(elem *list.Element) {
+	c.fair.leave(elem.Value.(*waiter))
+	c.queue.Remove(elem)
+}
+
+// next picks the queued collection to get workers: interactive class goes first,
+// clients of the class take turns, each client's collections go in order of arrival
+func (c *collector) next() *list.Element {
+	f := &c.fair
+
+	var class = PriorityBatch
+	if f.queued[PriorityInteractive] > 0 {
+		class = PriorityInteractive
+	}
+
+	if f.queued[class] == 0 {
+		return nil
+	}
+
+	for {
+		if f.turn == nil {
+			f.turn = f.ring.Front()
+		}
+
+		cq := f.turn.Value.(*clientQueue)
+
+		if waiters := cq.waiters[class]; waiters != nil && waiters.Len() > 0 {
+			if !f.credited {
+				cq.deficit += f.quantum(cq.client)
+				f.credited = true
+			}
+
+			head := waiters.Front().Value.(*list.Element)
+			if cq.deficit >= head.Value.(*waiter).count {
+				return head
+			}
+		}
+
+		// the turn is over, the deficit is kept for the next round
+		f.turn, f.credited = f.turn.Next(), false
+	}
+}
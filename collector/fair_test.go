@@ -0,0 +1,71 @@
+package collector
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func Test_collector_next_fairness(t *testing.T) {
+	type queued struct {
+		client string
+		count  int
+	}
+
+	tests := []struct {
+		name    string
+		weights map[string]int
+		queued  []queued
+		want    []int // order of picked collections
+	}{
+		{
+			name:   "clients take turns",
+			queued: []queued{{"a", 1}, {"a", 1}, {"a", 1}, {"b", 1}, {"b", 1}, {"c", 1}},
+			want:   []int{0, 3, 5, 1, 4, 2},
+		},
+		{
+			name:    "weighted client takes more",
+			weights: map[string]int{"b": 2},
+			queued:  []queued{{"a", 1}, {"a", 1}, {"a", 1}, {"b", 1}, {"b", 1}, {"b", 1}, {"b", 1}},
+			want:    []int{0, 3, 4, 1, 5, 6, 2},
+		},
+		{
+			name:   "big collection waits for its deficit",
+			queued: []queued{{"a", 2}, {"a", 1}, {"b", 1}, {"b", 1}, {"b", 1}},
+			want:   []int{2, 0, 3, 1, 4},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var opts = []Option{WithWaitQueue(len(tt.queued), time.Second)}
+			for client, weight := range tt.weights {
+				opts = append(opts, WithClientWeight(client, weight))
+			}
+
+			c := NewCollector(2, 0, time.Second, opts...).(*collector)
+
+			var index = make(map[*waiter]int)
+			for i, q := range tt.queued {
+				w := &waiter{count: q.count, priority: PriorityInteractive, client: q.client, ready: make(chan struct{})}
+				index[w] = i
+				c.push(w)
+			}
+
+			// workers are always enough, so only turns decide
+			var got []int
+			for elem := c.next(); elem != nil; elem = c.next() {
+				w := elem.Value.(*waiter)
+				got = append(got, index[w])
+				c.fair.charge(w)
+				c.remove(elem)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("next() order = %v, want %v", got, tt.want)
+			}
+			if len(c.fair.clients) != 0 || c.fair.ring.Len() != 0 {
+				t.Errorf("clients of the empty queue = %d and %d, want none", len(c.fair.clients), c.fair.ring.Len())
+			}
+		})
+	}
+}
@@ -0,0 +1,284 @@
+package collector
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var ErrUnsupportedScheme = errors.New("unsupported url scheme")
+
+var errOutOfRoot = errors.New("file is out of the root directory")
+
+var errInvalidSocket = errors.New("invalid socket path")
+
+// Fetcher gets the resource of the request, the collector applies body limits, retries and caching on top of it
+type Fetcher interface {
+	Fetch(ctx context.Context, req Request) (*Response, error)
+}
+
+// FetcherFunc is an adapter to use ordinary functions as fetchers
+type FetcherFunc func(ctx context.Context, req Request) (*Response, error)
+
+func (f FetcherFunc) Fetch(ctx context.Context, req Request) (*Response, error) {
+	return f(ctx, req)
+}
+
+type Response struct {
+	StatusCode int
+	Header     http.Header
+	Body       io.ReadCloser
+}
+
+// WithFetcher registers the fetcher for urls of the scheme, it replaces the built-in one if any
+func WithFetcher(scheme string, f Fetcher) Option {
+	return func(c *collector) {
+		c.fetchers[strings.ToLower(scheme)] = f
+	}
+}
+
+func defaultFetchers(client *http.Client) map[string]Fetcher {
+	web := httpFetcher{client: client}
+
+	return map[string]Fetcher{
+		"http":  web,
+		"https": web,
+		"data":  dataFetcher{},
+	}
+}
+
+func scheme(rawurl string) string {
+	i := strings.Index(rawurl, ":")
+	if i <= 0 {
+		return ""
+	}
+	return strings.ToLower(rawurl[:i])
+}
+
+type httpFetcher struct {
+	client *http.Client
+}
+
+func (f httpFetcher) Fetch(ctx context.Context, req Request) (*Response, error) {
+	return fetchHTTP(ctx, f.client, req.URL, req)
+}
+
+func fetchHTTP(ctx context.Context, client *http.Client, rawurl string, req Request) (*Response, error) {
+	r, err := http.NewRequestWithContext(ctx, req.method(), rawurl, req.body())
+	if err != nil {
+		return nil, err
+	}
+
+	for key, values := range req.Header {
+		r.Header[key] = values
+	}
+
+	resp, err := client.Do(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Response{StatusCode: resp.StatusCode, Header: resp.Header, Body: resp.Body}, nil
+}
+
+// NewUnixFetcher talks http over unix sockets, the socket path is escaped in the host: http+unix://%2Frun%2Fapp.sock/status,
+// it isn't registered by default, since it gives access to local services
+func NewUnixFetcher(timeout time.Duration) Fetcher {
+	return &unixFetcher{timeout: timeout, clients: make(map[string]*http.Client)}
+}
+
+type unixFetcher struct {
+	sync.Mutex
+	timeout time.Duration
+	clients map[string]*http.Client // by socket path, so connections are kept alive
+}
+
+func (f *unixFetcher) Fetch(ctx context.Context, req Request) (*Response, error) {
+	// the scheme is whatever the fetcher is registered for
+	var rest = req.URL
+	if i := strings.Index(rest, ":"); i >= 0 {
+		rest = rest[i+1:]
+	}
+	rest = strings.TrimPrefix(rest, "//")
+
+	var socket, path = rest, "/"
+	if i := strings.Index(rest, "/"); i >= 0 {
+		socket, path = rest[:i], rest[i:]
+	}
+
+	socket, err := url.PathUnescape(socket)
+	if err != nil || socket == "" {
+		return nil, errInvalidSocket
+	}
+
+	return fetchHTTP(ctx, f.client(socket), "http://unix"+path, req)
+}
+
+func (f *unixFetcher) client(socket string) *http.Client {
+	f.Lock()
+	defer f.Unlock()
+
+	client, ok := f.clients[socket]
+	if !ok {
+		client = &http.Client{
+			Timeout: f.timeout,
+			Transport: &http.Transport{
+				DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+					var dialer net.Dialer
+					return dialer.DialContext(ctx, "unix", socket)
+				},
+			},
+		}
+		f.clients[socket] = client
+	}
+
+	return client
+}
+
+// NewFileFetcher reads local files under the root directory, it isn't registered by default
+func NewFileFetcher(root string) Fetcher {
+	root = filepath.Clean(root)
+	if resolved, err := filepath.EvalSymlinks(root); err == nil {
+		root = resolved
+	}
+	return fileFetcher{root: root}
+}
+
+type fileFetcher struct {
+	root string
+}
+
+func (f fileFetcher) Fetch(ctx context.Context, req Request) (*Response, error) {
+	u, err := url.Parse(req.URL)
+	if err != nil {
+		return nil, err
+	}
+
+	if u.Host != "" && u.Host != "localhost" {
+		return nil, errors.New("only local files can be collected")
+	}
+
+	if m := req.method(); m != http.MethodGet && m != http.MethodHead {
+		return nil, fmt.Errorf("method %s isn't allowed for files", m)
+	}
+
+	name := filepath.Clean(filepath.FromSlash(u.Path))
+	if !f.within(name) {
+		return nil, errOutOfRoot
+	}
+
+	// symlinks under the root may lead out of it
+	name, err = filepath.EvalSymlinks(name)
+	if err != nil {
+		return nil, err
+	}
+	if !f.within(name) {
+		return nil, errOutOfRoot
+	}
+
+	file, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	if info.IsDir() {
+		file.Close()
+		return nil, fmt.Errorf("%s is a directory", name)
+	}
+
+	var body = bufio.NewReader(file)
+
+	contentType := mime.TypeByExtension(filepath.Ext(name))
+	if contentType == "" {
+		head, _ := body.Peek(512)
+		contentType = http.DetectContentType(head)
+	}
+
+	header := http.Header{}
+	header.Set("Content-Type", contentType)
+	header.Set("Content-Length", strconv.FormatInt(info.Size(), 10))
+	header.Set("Last-Modified", info.ModTime().UTC().Format(http.TimeFormat))
+
+	return &Response{
+		StatusCode: http.StatusOK,
+		Header:     header,
+		Body:       readCloser{Reader: body, Closer: file},
+	}, nil
+}
+
+func (f fileFetcher) within(name string) bool {
+	rel, err := filepath.Rel(f.root, name)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// dataFetcher decodes data urls of RFC 2397: data:[<mediatype>][;base64],<data>
+type dataFetcher struct{}
+
+func (dataFetcher) Fetch(ctx context.Context, req Request) (*Response, error) {
+	rest := req.URL[len("data:"):]
+
+	i := strings.Index(rest, ",")
+	if i < 0 {
+		return nil, errors.New("data url without comma")
+	}
+
+	meta, data := rest[:i], rest[i+1:]
+
+	var isBase64 bool
+	if strings.HasSuffix(strings.ToLower(meta), ";base64") {
+		isBase64 = true
+		meta = meta[:len(meta)-len(";base64")]
+	}
+
+	if meta == "" || strings.HasPrefix(meta, ";") {
+		meta = "text/plain;charset=US-ASCII" + meta
+	}
+
+	text, err := url.PathUnescape(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var body = []byte(text)
+	if isBase64 {
+		if body, err = base64.StdEncoding.DecodeString(text); err != nil {
+			return nil, err
+		}
+	}
+
+	header := http.Header{}
+	header.Set("Content-Type", meta)
+	header.Set("Content-Length", strconv.Itoa(len(body)))
+
+	return &Response{
+		StatusCode: http.StatusOK,
+		Header:     header,
+		Body:       ioutil.NopCloser(bytes.NewReader(body)),
+	}, nil
+}
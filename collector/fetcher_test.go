@@ -0,0 +1,183 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_collector_Collect_fetchers(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fetcher")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "page.html")
+	if err := ioutil.WriteFile(file, []byte("<p>file</p>"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	outside, err := ioutil.TempDir("", "outside")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(outside)
+
+	secret := filepath.Join(outside, "secret.txt")
+	if err := ioutil.WriteFile(secret, []byte("secret"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for link, target := range map[string]string{"inner.html": file, "secret.txt": secret, "outside": outside} {
+		if err := os.Symlink(target, filepath.Join(dir, link)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	socket := filepath.Join(dir, "app.sock")
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "unix %s", r.URL.Path)
+	})}
+	go srv.Serve(listener)
+	defer srv.Close()
+
+	custom := FetcherFunc(func(ctx context.Context, req Request) (*Response, error) {
+		return &Response{
+			StatusCode: http.StatusAccepted,
+			Header:     http.Header{"Content-Type": {"text/plain"}},
+			Body:       ioutil.NopCloser(strings.NewReader("custom " + req.URL)),
+		}, nil
+	})
+
+	tests := []struct {
+		name            string
+		url             string
+		wantBody        string
+		wantStatusCode  int
+		wantContentType string
+		wantErr         error
+	}{
+		{
+			name:            "plain data url",
+			url:             "data:,hello%20world",
+			wantBody:        "hello world",
+			wantStatusCode:  http.StatusOK,
+			wantContentType: "text/plain;charset=US-ASCII",
+		},
+		{
+			name:            "base64 data url",
+			url:             "data:application/json;base64,eyJvayI6dHJ1ZX0=",
+			wantBody:        `{"ok":true}`,
+			wantStatusCode:  http.StatusOK,
+			wantContentType: "application/json",
+		},
+		{
+			name:            "local file",
+			url:             "file://" + file,
+			wantBody:        "<p>file</p>",
+			wantStatusCode:  http.StatusOK,
+			wantContentType: "text/html; charset=utf-8",
+		},
+		{
+			name:    "file out of the root",
+			url:     "file://" + filepath.Join(dir, "..", "passwd"),
+			wantErr: errOutOfRoot,
+		},
+		{
+			name:            "symlink in the root",
+			url:             "file://" + filepath.Join(dir, "inner.html"),
+			wantBody:        "<p>file</p>",
+			wantStatusCode:  http.StatusOK,
+			wantContentType: "text/html; charset=utf-8",
+		},
+		{
+			name:    "symlink out of the root",
+			url:     "file://" + filepath.Join(dir, "secret.txt"),
+			wantErr: errOutOfRoot,
+		},
+		{
+			name:    "file under symlinked directory out of the root",
+			url:     "file://" + filepath.Join(dir, "outside", "secret.txt"),
+			wantErr: errOutOfRoot,
+		},
+		{
+			name:    "missing file",
+			url:     "file://" + filepath.Join(dir, "missing"),
+			wantErr: os.ErrNotExist,
+		},
+		{
+			name:            "http over unix socket",
+			url:             "http+unix://" + url.PathEscape(socket) + "/status",
+			wantBody:        "unix /status",
+			wantStatusCode:  http.StatusOK,
+			wantContentType: "text/plain; charset=utf-8",
+		},
+		{
+			name:            "unix socket under another scheme",
+			url:             "unix:" + url.PathEscape(socket) + "/status",
+			wantBody:        "unix /status",
+			wantStatusCode:  http.StatusOK,
+			wantContentType: "text/plain; charset=utf-8",
+		},
+		{
+			name:    "unix url without socket",
+			url:     "unix:",
+			wantErr: errInvalidSocket,
+		},
+		{
+			name:            "registered fetcher",
+			url:             "mem://key",
+			wantBody:        "custom mem://key",
+			wantStatusCode:  http.StatusAccepted,
+			wantContentType: "text/plain",
+		},
+		{
+			name:    "unsupported scheme",
+			url:     "ftp://example.com/file",
+			wantErr: ErrUnsupportedScheme,
+		},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	c := NewCollector(1, 0, time.Second,
+		WithFetcher("file", NewFileFetcher(dir)),
+		WithFetcher("http+unix", NewUnixFetcher(time.Second)),
+		WithFetcher("unix", NewUnixFetcher(time.Second)),
+		WithFetcher("MEM", custom),
+	)
+	c.Start(ctx)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := c.CollectPartial(context.Background(), []Request{{URL: tt.url}}, 1)
+			if err != nil {
+				t.Fatalf("CollectPartial() error = %v", err)
+			}
+
+			r := got[0]
+			if !errors.Is(r.Err(), tt.wantErr) {
+				t.Fatalf("CollectPartial() result error = %v, wantErr %v", r.Err(), tt.wantErr)
+			}
+			if r.Body != tt.wantBody || r.StatusCode != tt.wantStatusCode || r.ContentType != tt.wantContentType {
+				t.Errorf("CollectPartial() got body = %q, status = %d, content type = %q, want %q, %d, %q",
+					r.Body, r.StatusCode, r.ContentType, tt.wantBody, tt.wantStatusCode, tt.wantContentType)
+			}
+		})
+	}
+}
@@ -0,0 +1,149 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// WithCoalescing makes identical in-flight requests of all collections share one upstream fetch
+func WithCoalescing() Option {
+	return func(c *collector) {
+		c.flights = &flights{calls: make(map[string]*call)}
+	}
+}
+
+type call struct {
+	done     chan struct{}
+	result   Result
+	waiters  int
+	deadline time.Time // latest deadline of the waiters, zero if any of them has none
+	cancel   context.CancelFunc
+}
+
+// join counts the waiter in, the fetch has to serve until the latest deadline of the waiters
+func (cl *call) join(ctx context.Context) {
+	cl.waiters++
+
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		cl.deadline = time.Time{}
+		return
+	}
+
+	if !cl.deadline.IsZero() && deadline.After(cl.deadline) {
+		cl.deadline = deadline
+	}
+}
+
+// flightContext is the detached context of the shared fetch, it reports the deadline of the waiters,
+// so retries don't wait longer than anyone waits for the result, it's canceled when all of them are gone
+type flightContext struct {
+	context.Context
+	flights *flights
+	call    *call
+}
+
+func (ctx flightContext) Deadline() (time.Time, bool) {
+	ctx.flights.Lock()
+	defer ctx.flights.Unlock()
+
+	return ctx.call.deadline, !ctx.call.deadline.IsZero()
+}
+
+type flights struct {
+	sync.Mutex
+	calls map[string]*call
+}
+
+// flightKey identifies requests which get the same result, only requests without body are coalesced
+func flightKey(r Request) (string, bool) {
+	switch r.method() {
+	case http.MethodGet, http.MethodHead:
+	default:
+		return "", false
+	}
+
+	if r.Body != "" {
+		return "", false
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%s %s %d %s %d %s", r.method(), r.URL, r.MaxBodySize, r.BodyPolicy, r.MaxAttempts, r.Cache)
+	writeHeader(&sb, r.Header)
+
+	return sb.String(), true
+}
+
+// writeHeader writes the header sorted by keys, so equal headers give equal keys
+func writeHeader(sb *strings.Builder, header http.Header) {
+	var keys = make([]string, 0, len(header))
+	for key := range header {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		fmt.Fprintf(sb, "\n%s: %s", key, strings.Join(header[key], ", "))
+	}
+}
+
+// do joins the in-flight fetch of the same request or starts a new one,
+// the fetch is detached from callers and canceled only when all of them are gone
+func (f *flights) do(prm param, key string, fetch func(param) Result) Result {
+	f.Lock()
+	cl, shared := f.calls[key]
+	if !shared {
+		ctx, cancel := context.WithCancel(context.Background())
+		cl = &call{done: make(chan struct{}), cancel: cancel}
+		cl.deadline, _ = prm.ctx.Deadline()
+		f.calls[key] = cl
+
+		leader := prm
+		leader.ctx = flightContext{Context: ctx, flights: f, call: cl}
+
+		go func() {
+			cl.result = fetch(leader)
+
+			f.forget(key, cl)
+			cancel()
+			close(cl.done)
+		}()
+	}
+	cl.join(prm.ctx)
+	f.Unlock()
+
+	select {
+	case <-cl.done:
+		result := cl.result
+		result.Index = prm.index
+		result.Shared = shared
+		return result
+	case <-prm.ctx.Done():
+		f.Lock()
+		if cl.waiters--; cl.waiters == 0 {
+			cl.cancel()
+			f.forgetLocked(key, cl)
+		}
+		f.Unlock()
+
+		return failed(prm, fmt.Errorf("%s :%w", prm.req.URL, prm.ctx.Err()))
+	}
+}
+
+func (f *flights) forget(key string, cl *call) {
+	f.Lock()
+	defer f.Unlock()
+	f.forgetLocked(key, cl)
+}
+
+// forgetLocked removes the call, so new requests don't join the finished or abandoned one
+func (f *flights) forgetLocked(key string, cl *call) {
+	if f.calls[key] == cl {
+		delete(f.calls, key)
+	}
+}
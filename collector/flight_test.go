@@ -0,0 +1,246 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func Test_flightKey(t *testing.T) {
+	tests := []struct {
+		name   string
+		a, b   Request
+		wantOk bool
+		same   bool
+	}{
+		{
+			name:   "same requests",
+			a:      Request{URL: "http://example.com", Header: http.Header{"Accept": {"text/html"}}},
+			b:      Request{URL: "http://example.com", Method: http.MethodGet, Header: http.Header{"Accept": {"text/html"}}},
+			wantOk: true,
+			same:   true,
+		},
+		{
+			name:   "different headers",
+			a:      Request{URL: "http://example.com", Header: http.Header{"Authorization": {"a"}}},
+			b:      Request{URL: "http://example.com", Header: http.Header{"Authorization": {"b"}}},
+			wantOk: true,
+		},
+		{
+			name:   "different body limits",
+			a:      Request{URL: "http://example.com"},
+			b:      Request{URL: "http://example.com", MaxBodySize: 10},
+			wantOk: true,
+		},
+		{
+			name: "requests with body",
+			a:    Request{URL: "http://example.com", Method: http.MethodPost, Body: "{}"},
+			b:    Request{URL: "http://example.com", Method: http.MethodPost, Body: "{}"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, okA := flightKey(tt.a)
+			b, okB := flightKey(tt.b)
+
+			if okA != tt.wantOk || okB != tt.wantOk {
+				t.Fatalf("flightKey() ok = %v, %v, want %v", okA, okB, tt.wantOk)
+			}
+			if tt.wantOk && (a == b) != tt.same {
+				t.Errorf("flightKey() %q and %q, want same = %v", a, b, tt.same)
+			}
+		})
+	}
+}
+
+func Test_collector_Collect_coalescing(t *testing.T) {
+	var hits, canceled int32
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+
+		select {
+		case <-time.After(time.Millisecond * 200):
+			fmt.Fprint(w, "some_text")
+		case <-r.Context().Done():
+			atomic.AddInt32(&canceled, 1)
+		}
+	}))
+	defer ts.Close()
+
+	tests := []struct {
+		name         string
+		collections  int
+		timeout      func(i int) time.Duration // timeout of each collection, no timeout if zero
+		wantHits     int32
+		wantCanceled int32
+		wantOk       int
+	}{
+		{
+			name:        "one fetch for all collections",
+			collections: 10,
+			timeout:     func(i int) time.Duration { return 0 },
+			wantHits:    1,
+			wantOk:      10,
+		},
+		{
+			name:        "leaving callers don't cancel the rest",
+			collections: 10,
+			timeout: func(i int) time.Duration {
+				if i%2 == 0 {
+					return time.Millisecond * 50
+				}
+				return 0
+			},
+			wantHits: 1,
+			wantOk:   5,
+		},
+		{
+			name:         "fetch is canceled when all callers are gone",
+			collections:  4,
+			timeout:      func(i int) time.Duration { return time.Millisecond * 50 },
+			wantHits:     1,
+			wantCanceled: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			atomic.StoreInt32(&hits, 0)
+			atomic.StoreInt32(&canceled, 0)
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			c := NewCollector(tt.collections, 0, time.Second, WithCoalescing())
+			c.Start(ctx)
+
+			var ok int32
+			var wg sync.WaitGroup
+
+			for i := 0; i < tt.collections; i++ {
+				collCtx := context.Background()
+				if tmt := tt.timeout(i); tmt > 0 {
+					collCtx = withTimeout(collCtx, tmt)
+				}
+
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					got, err := c.CollectPartial(collCtx, makeUrls(ts, 1), 1)
+					if err == nil && got[0].Body == "some_text" {
+						atomic.AddInt32(&ok, 1)
+					}
+				}()
+			}
+			wg.Wait()
+
+			time.Sleep(time.Millisecond * 50) // let upstream notice the cancellation
+
+			if got := atomic.LoadInt32(&hits); got != tt.wantHits {
+				t.Errorf("upstream hits = %d, want %d", got, tt.wantHits)
+			}
+			if got := atomic.LoadInt32(&canceled); got != tt.wantCanceled {
+				t.Errorf("canceled upstream requests = %d, want %d", got, tt.wantCanceled)
+			}
+			if int(ok) != tt.wantOk {
+				t.Errorf("successful collections = %d, want %d", ok, tt.wantOk)
+			}
+		})
+	}
+}
+
+func Test_collector_Collect_coalescing_deadline(t *testing.T) {
+	var hits int32
+	var gate chan struct{}
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		<-gate // all collections join the shared fetch
+
+		w.Header().Set("Retry-After", "10")
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer ts.Close()
+
+	tests := []struct {
+		name      string
+		timeouts  []time.Duration // of each collection, no timeout if zero
+		wantSleep bool            // the shared fetch waits for retry
+	}{
+		{
+			name:     "deadline of the collection",
+			timeouts: []time.Duration{time.Second},
+		},
+		{
+			name:     "latest deadline of the collections",
+			timeouts: []time.Duration{time.Second, time.Second * 2},
+		},
+		{
+			name:      "collection without deadline",
+			timeouts:  []time.Duration{time.Second, 0},
+			wantSleep: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			atomic.StoreInt32(&hits, 0)
+			gate = make(chan struct{})
+
+			c := NewCollector(1, 0, time.Second, WithCoalescing(), WithRetryPolicy(RetryPolicy{MaxAttempts: 2})).(*collector)
+
+			var wg sync.WaitGroup
+			for _, tmt := range tt.timeouts {
+				ctx, cancel := context.WithCancel(context.Background())
+				if tmt > 0 {
+					ctx, cancel = context.WithTimeout(context.Background(), tmt)
+				}
+				defer cancel()
+
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					c.fetch(param{ctx: ctx, req: Request{URL: ts.URL}})
+				}()
+			}
+
+			time.Sleep(time.Millisecond * 50)
+			close(gate)
+
+			var done = make(chan struct{})
+			go func() {
+				wg.Wait()
+				close(done)
+			}()
+
+			select {
+			case <-done:
+				if tt.wantSleep {
+					t.Errorf("shared fetch didn't wait for retry")
+				}
+			case <-time.After(time.Millisecond * 500):
+				if !tt.wantSleep {
+					t.Errorf("shared fetch waits for retry beyond the deadline")
+				}
+			}
+
+			if got := atomic.LoadInt32(&hits); got != 1 {
+				t.Errorf("upstream hits = %d, want 1", got)
+			}
+
+			c.flights.Lock()
+			for _, cl := range c.flights.calls {
+				cl.cancel() // stop the sleeping fetch
+			}
+			c.flights.Unlock()
+			<-done
+		})
+	}
+}
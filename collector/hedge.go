@@ -0,0 +1,194 @@
+package collector
+
+import (
+	"container/list"
+	"context"
+	"math"
+	"net/http"
+	"net/url"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	latencySamples    = 128  // latencies kept per host
+	latencyHosts      = 1024 // hosts which latencies are kept, the least recently requested one is forgotten above it
+	latencyMinSamples = 16   // samples needed to trust the percentile
+	hedgeMaxTokens    = 10   // burst of hedges the budget can save up
+)
+
+type HedgePolicy struct {
+	Percentile float64       // latency percentile of the host after which the hedge is sent, e.g. 0.95
+	MinDelay   time.Duration // lower bound of the hedge delay, it's used until the host has enough samples
+	Budget     float64       // ratio of hedged requests to all requests, e.g. 0.05
+}
+
+// WithHedging sends the second identical request when the first one is slower than
+// the usual latency of the host and takes whichever answers first
+func WithHedging(policy HedgePolicy) Option {
+	return func(c *collector) {
+		c.hedger = &hedger{policy: policy, latencies: make(map[string]*list.Element), lru: list.New()}
+	}
+}
+
+type latencies struct {
+	host    string
+	samples [latencySamples]time.Duration
+	next    int
+	count   int
+}
+
+func (l *latencies) add(d time.Duration) {
+	l.samples[l.next] = d
+	l.next = (l.next + 1) % len(l.samples)
+	if l.count < len(l.samples) {
+		l.count++
+	}
+}
+
+func (l *latencies) percentile(p float64) time.Duration {
+	var sorted = make([]time.Duration, l.count)
+	copy(sorted, l.samples[:l.count])
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	i := int(math.Ceil(p*float64(len(sorted)))) - 1
+	if i < 0 {
+		i = 0
+	}
+
+	return sorted[i]
+}
+
+type hedger struct {
+	sync.Mutex
+	policy    HedgePolicy
+	latencies map[string]*list.Element
+	lru       *list.List // the least recently requested host is at the back
+	tokens    float64
+}
+
+func (h *hedger) delay(host string) time.Duration {
+	h.Lock()
+	defer h.Unlock()
+
+	elem, ok := h.latencies[host]
+	if !ok || elem.Value.(*latencies).count < latencyMinSamples {
+		return h.policy.MinDelay
+	}
+
+	l := elem.Value.(*latencies)
+
+	if d := l.percentile(h.policy.Percentile); d > h.policy.MinDelay {
+		return d
+	}
+
+	return h.policy.MinDelay
+}
+
+func (h *hedger) observe(host string, d time.Duration) {
+	h.Lock()
+	defer h.Unlock()
+
+	if elem, ok := h.latencies[host]; ok {
+		h.lru.MoveToFront(elem)
+		elem.Value.(*latencies).add(d)
+		return
+	}
+
+	if h.lru.Len() >= latencyHosts {
+		back := h.lru.Remove(h.lru.Back()).(*latencies)
+		delete(h.latencies, back.host)
+	}
+
+	l := &latencies{host: host}
+	l.add(d)
+	h.latencies[host] = h.lru.PushFront(l)
+}
+
+// earn saves a part of the hedge for each sent request
+func (h *hedger) earn() {
+	h.Lock()
+	defer h.Unlock()
+
+	if h.tokens = h.tokens + h.policy.Budget; h.tokens > hedgeMaxTokens {
+		h.tokens = hedgeMaxTokens
+	}
+}
+
+func (h *hedger) spend() bool {
+	h.Lock()
+	defer h.Unlock()
+
+	if h.tokens < 1 {
+		return false
+	}
+
+	h.tokens--
+	return true
+}
+
+func hedgeable(r Request) bool {
+	switch r.method() {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return r.Body == ""
+	}
+	return false
+}
+
+// doHedged makes the request and hedges it if it's slower than the usual latency of the host,
+// the first successful answer wins and the other request is canceled
+func (c *collector) doHedged(prm param) Result {
+	if c.hedger == nil || !hedgeable(prm.req) {
+		return c.do(prm)
+	}
+
+	u, err := url.Parse(prm.req.URL)
+	if err != nil || u.Host == "" {
+		return c.do(prm)
+	}
+
+	ctx, cancel := context.WithCancel(prm.ctx)
+	defer cancel()
+
+	var results = make(chan Result, 2)
+	var send = func() {
+		p := prm
+		p.ctx = ctx
+		go func() { results <- c.do(p) }()
+	}
+
+	c.hedger.earn()
+	send()
+
+	timer := time.NewTimer(c.hedger.delay(u.Host))
+	defer timer.Stop()
+
+	var sent, received = 1, 0
+	var result Result
+
+	for received < sent {
+		select {
+		case <-timer.C:
+			if c.hedger.spend() {
+				sent++
+				send()
+			}
+			continue
+		case result = <-results:
+			received++
+		}
+
+		if result.err == nil {
+			break
+		}
+	}
+
+	if result.err == nil {
+		c.hedger.observe(u.Host, result.Duration)
+	}
+
+	result.Hedged = sent > 1
+
+	return result
+}
@@ -0,0 +1,158 @@
+package collector
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func Test_latencies_percentile(t *testing.T) {
+	tests := []struct {
+		name       string
+		samples    []time.Duration
+		percentile float64
+		want       time.Duration
+	}{
+		{
+			name:       "median",
+			samples:    []time.Duration{5, 1, 4, 2, 3},
+			percentile: 0.5,
+			want:       3,
+		},
+		{
+			name:       "p90",
+			samples:    []time.Duration{10, 9, 8, 7, 6, 5, 4, 3, 2, 1},
+			percentile: 0.9,
+			want:       9,
+		},
+		{
+			name:       "p100",
+			samples:    []time.Duration{10, 9, 8, 7, 6, 5, 4, 3, 2, 1},
+			percentile: 1,
+			want:       10,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var l latencies
+			for _, d := range tt.samples {
+				l.add(d)
+			}
+
+			if got := l.percentile(tt.percentile); got != tt.want {
+				t.Errorf("percentile() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_hedger_observe(t *testing.T) {
+	tests := []struct {
+		name     string
+		touch    bool // the first host is requested again before the rest
+		wantKept bool
+	}{
+		{
+			name: "least recently requested host is forgotten",
+		},
+		{
+			name:     "recently requested host is kept",
+			touch:    true,
+			wantKept: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &hedger{latencies: make(map[string]*list.Element), lru: list.New()}
+
+			h.observe("first", time.Millisecond)
+			for i := 1; i < latencyHosts; i++ {
+				h.observe(fmt.Sprintf("host-%d", i), time.Millisecond)
+			}
+
+			if tt.touch {
+				h.observe("first", time.Millisecond)
+			}
+			h.observe("last", time.Millisecond)
+
+			if len(h.latencies) != latencyHosts || h.lru.Len() != latencyHosts {
+				t.Errorf("hosts = %d and %d, want %d", len(h.latencies), h.lru.Len(), latencyHosts)
+			}
+			if _, ok := h.latencies["first"]; ok != tt.wantKept {
+				t.Errorf("first host is kept = %v, want %v", ok, tt.wantKept)
+			}
+		})
+	}
+}
+
+func Test_collector_doHedged(t *testing.T) {
+	var hits int32
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&hits, 1) == 1 { // the first request is stuck
+			select {
+			case <-time.After(time.Second):
+			case <-r.Context().Done():
+				return
+			}
+		}
+		fmt.Fprint(w, "some_text")
+	}))
+	defer ts.Close()
+
+	tests := []struct {
+		name       string
+		budget     float64
+		wantHedged bool
+		wantHits   int32
+		maxTime    time.Duration
+	}{
+		{
+			name:       "hedge cuts the latency",
+			budget:     1,
+			wantHedged: true,
+			wantHits:   2,
+			maxTime:    time.Millisecond * 500,
+		},
+		{
+			name:     "out of budget",
+			budget:   0.5,
+			wantHits: 1,
+			maxTime:  time.Second * 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			atomic.StoreInt32(&hits, 0)
+
+			c := NewCollector(1, 0, time.Second*2, WithHedging(HedgePolicy{
+				Percentile: 0.9,
+				MinDelay:   time.Millisecond * 50,
+				Budget:     tt.budget,
+			})).(*collector)
+
+			start := time.Now()
+
+			got := c.doHedged(param{ctx: context.Background(), req: Request{URL: ts.URL}})
+			if got.Err() != nil || got.Body != "some_text" {
+				t.Fatalf("doHedged() got %+v", got)
+			}
+
+			if n := atomic.LoadInt32(&hits); got.Hedged != tt.wantHedged || n != tt.wantHits {
+				t.Errorf("doHedged() hedged = %v, hits = %d, want %v and %d", got.Hedged, n, tt.wantHedged, tt.wantHits)
+			}
+
+			if elapsed := time.Since(start); elapsed > tt.maxTime {
+				t.Errorf("doHedged() took %v, want less than %v", elapsed, tt.maxTime)
+			}
+		})
+	}
+}
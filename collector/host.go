@@ -0,0 +1,150 @@
+package collector
+
+import (
+	"context"
+	"net"
+	"net/url"
+	"path"
+	"strings"
+	"sync"
+)
+
+type hostRule struct {
+	pattern string // host name or glob like *.example.com, * matches all hosts
+	limit   int
+}
+
+// WithHostLimit caps number of concurrent requests to each host matching the pattern across all collections,
+// the first added matching rule wins, so add specific patterns before general ones
+func WithHostLimit(pattern string, limit int) Option {
+	return func(c *collector) {
+		c.hostRules = append(c.hostRules, hostRule{pattern: strings.ToLower(pattern), limit: limit})
+	}
+}
+
+// WithIPLimit caps number of concurrent requests to each resolved upstream ip across all collections
+func WithIPLimit(limit int) Option {
+	return func(c *collector) {
+		c.ipLimit = limit
+	}
+}
+
+func (c *collector) hostLimit(host string) int {
+	for _, rule := range c.hostRules {
+		if ok, _ := path.Match(rule.pattern, host); ok {
+			return rule.limit
+		}
+	}
+	return 0
+}
+
+// acquireHost takes slots of the host and its ip, the returned func gives them back,
+// ip is limited only for http urls since other fetchers don't dial the host
+func (c *collector) acquireHost(ctx context.Context, rawurl string) (func(), error) {
+	var release = func() {}
+
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return release, nil
+	}
+
+	host := strings.ToLower(u.Hostname())
+	if host == "" {
+		return release, nil
+	}
+
+	if limit := c.hostLimit(host); limit > 0 {
+		if err := c.hostSems.acquire(ctx, "host:"+host, limit); err != nil {
+			return nil, err
+		}
+		release = func() { c.hostSems.release("host:" + host) }
+	}
+
+	if c.ipLimit <= 0 || u.Scheme != "http" && u.Scheme != "https" {
+		return release, nil
+	}
+
+	ip := host
+	if net.ParseIP(host) == nil {
+		addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+		if err != nil {
+			release()
+			return nil, err
+		}
+		if len(addrs) > 0 { // dialer connects to the first address
+			ip = addrs[0].IP.String()
+		}
+	}
+
+	if err := c.hostSems.acquire(ctx, "ip:"+ip, c.ipLimit); err != nil {
+		release()
+		return nil, err
+	}
+
+	releaseHost := release
+	return func() {
+		c.hostSems.release("ip:" + ip)
+		releaseHost()
+	}, nil
+}
+
+type semaphore struct {
+	slots chan struct{}
+	refs  int
+}
+
+// semaphores is a set of counting semaphores by key, unused ones are dropped
+type semaphores struct {
+	sync.Mutex
+	sems map[string]*semaphore
+}
+
+func (s *semaphores) get(key string, limit int) *semaphore {
+	s.Lock()
+	defer s.Unlock()
+
+	if s.sems == nil {
+		s.sems = make(map[string]*semaphore)
+	}
+
+	sem, ok := s.sems[key]
+	if !ok {
+		sem = &semaphore{slots: make(chan struct{}, limit)}
+		s.sems[key] = sem
+	}
+	sem.refs++
+
+	return sem
+}
+
+func (s *semaphores) put(key string) {
+	s.Lock()
+	defer s.Unlock()
+
+	if sem, ok := s.sems[key]; ok {
+		if sem.refs--; sem.refs == 0 {
+			delete(s.sems, key)
+		}
+	}
+}
+
+func (s *semaphores) acquire(ctx context.Context, key string, limit int) error {
+	sem := s.get(key, limit)
+
+	select {
+	case sem.slots <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		s.put(key)
+		return ctx.Err()
+	}
+}
+
+func (s *semaphores) release(key string) {
+	s.Lock()
+	sem := s.sems[key]
+	s.Unlock()
+
+	<-sem.slots
+	s.put(key)
+}
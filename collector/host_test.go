@@ -0,0 +1,118 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func Test_collector_hostLimit(t *testing.T) {
+	tests := []struct {
+		name  string
+		rules []hostRule
+		host  string
+		want  int
+	}{
+		{
+			name: "no rules",
+			host: "example.com",
+		},
+		{
+			name:  "exact host",
+			rules: []hostRule{{"example.com", 2}, {"*", 10}},
+			host:  "example.com",
+			want:  2,
+		},
+		{
+			name:  "subdomain pattern",
+			rules: []hostRule{{"*.example.com", 3}, {"*", 10}},
+			host:  "api.example.com",
+			want:  3,
+		},
+		{
+			name:  "fallback to all hosts",
+			rules: []hostRule{{"*.example.com", 3}, {"*", 10}},
+			host:  "example.org",
+			want:  10,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &collector{hostRules: tt.rules}
+			if got := c.hostLimit(tt.host); got != tt.want {
+				t.Errorf("hostLimit() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_collector_Collect_hostLimit(t *testing.T) {
+	var inFlight, maxInFlight int32
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&inFlight, 1)
+		defer atomic.AddInt32(&inFlight, -1)
+
+		for {
+			max := atomic.LoadInt32(&maxInFlight)
+			if n <= max || atomic.CompareAndSwapInt32(&maxInFlight, max, n) {
+				break
+			}
+		}
+
+		time.Sleep(time.Millisecond * 20)
+		fmt.Fprint(w, "some_text")
+	}))
+	defer ts.Close()
+
+	tests := []struct {
+		name string
+		opts []Option
+		want int32
+	}{
+		{
+			name: "per host",
+			opts: []Option{WithHostLimit("127.0.0.1", 2)},
+			want: 2,
+		},
+		{
+			name: "per ip",
+			opts: []Option{WithIPLimit(3)},
+			want: 3,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			atomic.StoreInt32(&maxInFlight, 0)
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			c := NewCollector(8, 0, time.Second, tt.opts...)
+			c.Start(ctx)
+
+			var wg sync.WaitGroup
+			for i := 0; i < 2; i++ { // few collections share the limit
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					if _, err := c.Collect(context.Background(), makeUrls(ts, 8), 4); err != nil {
+						t.Errorf("Collect() error = %v", err)
+					}
+				}()
+			}
+			wg.Wait()
+
+			if got := atomic.LoadInt32(&maxInFlight); got != tt.want {
+				t.Errorf("max concurrent requests = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
@@ -4,5 +4,10 @@ import "context"
 
 type Collector interface {
 	Start(ctx context.Context)
-	Collect(ctx context.Context, urls []string, limit int) ([]res, error)
+	Shutdown(ctx context.Context) error
+	Collect(ctx context.Context, reqs []Request, limit int) ([]Result, error)
+	CollectPartial(ctx context.Context, reqs []Request, limit int) ([]Result, error)
+	Stream(ctx context.Context, reqs []Request, limit int) (<-chan Result, error)
+	Breakers() []BreakerStatus
+	Stats() Stats
 }
@@ -0,0 +1,30 @@
+package collector
+
+import "time"
+
+type Option func(c *collector)
+
+// WithMaxBodySize bounds each response body by size bytes, policy decides what to do with the bigger ones,
+// requests can lower the size and choose their own policy, but never exceed the collector's size
+func WithMaxBodySize(size int64, policy BodyPolicy) Option {
+	return func(c *collector) {
+		c.maxBodySize = size
+		c.bodyPolicy = policy
+	}
+}
+
+// WithMinWorkers sets number of workers which are never reaped, it's number of fixed workers by default
+func WithMinWorkers(min int) Option {
+	return func(c *collector) {
+		c.min = min
+	}
+}
+
+// WithIdleTimeout reaps workers above the minimum after idle time, but not faster than one per shrink interval,
+// by default extra workers leave right after their collection
+func WithIdleTimeout(idle, shrink time.Duration) Option {
+	return func(c *collector) {
+		c.idle = idle
+		c.shrink = shrink
+	}
+}
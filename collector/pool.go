@@ -1,6 +1,7 @@
 package collector
 
 import (
+	"container/list"
 	"context"
 	"errors"
 	"log"
@@ -9,45 +10,88 @@ import (
 	"time"
 )
 
-type res struct {
-	Url  string
-	Body string
-}
+var now = time.Now
+
+var ErrInvalidLimit = errors.New("limit of concurrent requests of the collection should be positive")
 
 type param struct {
-	url   string
+	index int
+	req   Request
 	ctx   context.Context
-	resCh chan res
-	errCh chan error
+	resCh chan Result
 }
 
 type collector struct {
 	sync.RWMutex
-	workersCh chan chan param
-	fixed     int
-	overflow  int
-	spawned   int
+	workersCh chan chan param // idle workers wait for collections on it
+	done      chan struct{}   // closed when the pool is stopped
+	halt      chan struct{}   // closed when in-flight collections are canceled
+	haltOnce  sync.Once
+	stopOnce  sync.Once
+	fixed     int           // workers started with the pool
+	overflow  int           // workers which can be spawned on demand above fixed ones
+	min       int           // workers which are never reaped
+	idle      time.Duration // idle time after which workers above min are reaped
+	shrink    time.Duration // minimal interval between reaping of two workers
+	workers   int           // alive workers
+	busy      int           // workers acquired by collections
+	lastID    int
+	reapedAt  time.Time
 	closed    bool
+	reserve   int            // workers which are kept for interactive collections
+	running   sync.WaitGroup // in-flight collections
+	queue     *list.List     // collections waiting for workers of the full pool
+	queueSize int
+	maxWait   time.Duration
+	fair      fairness // turns of clients in the queue
+	stats     Stats
 	client    *http.Client
+	fetchers  map[string]Fetcher // by url scheme
+
+	maxBodySize int64
+	bodyPolicy  BodyPolicy
+	retry       RetryPolicy
+
+	hostRules []hostRule
+	ipLimit   int
+	hostSems  semaphores // shared by all collections
+
+	breakers *breakers // nil if circuit breaker is off
+	cache    Cache     // nil if responses are not cached
+	flights  *flights  // nil if requests are not coalesced
+	hedger   *hedger   // nil if requests are not hedged
 }
 
-func NewCollector(fixed, overflow int, timeout time.Duration) Collector {
-	return &collector{
+func NewCollector(fixed, overflow int, timeout time.Duration, opts ...Option) Collector {
+	c := &collector{
 		fixed:     fixed,
 		overflow:  overflow,
-		workersCh: make(chan chan param, fixed),
+		min:       fixed,
+		workersCh: make(chan chan param),
+		done:      make(chan struct{}),
+		halt:      make(chan struct{}),
+		queue:     list.New(),
+		fair:      newFairness(),
 		client: &http.Client{
 			Transport: http.DefaultTransport,
 			Timeout:   timeout,
 		},
 	}
+
+	c.fetchers = defaultFetchers(c.client)
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	return c
 }
 
 func (c *collector) Start(ctx context.Context) {
 	defer log.Println("workers pool was started")
 
-	for i := 1; i <= c.fixed; i++ {
-		go c.fixedWorker(i, c.workersCh)
+	for i := 0; i < c.fixed; i++ {
+		c.spawn(nil)
 	}
 
 	go func() {
@@ -58,90 +102,285 @@ func (c *collector) Start(ctx context.Context) {
 	}()
 }
 
-func (c *collector) stop() {
-	defer log.Println("workers pool was stopped")
+func (c *collector) Breakers() []BreakerStatus {
+	if c.breakers == nil {
+		return nil
+	}
+	return c.breakers.statuses()
+}
+
+// Shutdown stops accepting collections and lets in-flight ones finish until ctx is done, then cancels the rest
+func (c *collector) Shutdown(ctx context.Context) error {
 	c.Lock()
 	c.closed = true
 	c.Unlock()
-	close(c.workersCh)
-}
 
-func (c *collector) isStopped() bool {
-	c.RLock()
-	defer c.RUnlock()
-	return c.closed
+	var drained = make(chan struct{})
+	go func() {
+		c.running.Wait()
+		close(drained)
+	}()
+
+	var err error
+	select {
+	case <-drained:
+	case <-ctx.Done():
+		err = ctx.Err()
+		c.cancel()
+		<-drained
+	}
+
+	c.stop()
+
+	return err
 }
 
-func (c *collector) getSpawnedCount() int {
-	c.RLock()
-	defer c.RUnlock()
-	return c.spawned
+func (c *collector) stop() {
+	c.stopOnce.Do(func() {
+		defer log.Println("workers pool was stopped")
+		c.Lock()
+		c.closed = true
+		c.Unlock()
+		close(c.done)
+	})
 }
 
-func (c *collector) incSpawnedCount(n int) int {
-	c.Lock()
-	defer c.Unlock()
-	c.spawned += n
-	return c.spawned
+func (c *collector) cancel() {
+	c.haltOnce.Do(func() {
+		close(c.halt)
+	})
 }
 
-func (c *collector) acquireWorkers(count, buffSize int) (chan param, error) {
-	if c.isStopped() {
-		return nil, errors.New("can't acquire workers from stopped pool")
-	}
+func (c *collector) acquireWorkers(ctx context.Context, count, buffSize int) (chan param, error) {
+	var p = priorityOf(ctx)
 
-	if count > c.fixed+c.overflow {
+	c.Lock()
+	switch {
+	case c.closed:
+		c.stats.Rejected++
+		c.Unlock()
+		return nil, ErrPoolStopped
+	case count > c.capacity(p):
+		c.stats.Rejected++
+		c.Unlock()
 		return nil, errors.New("acquired workers more that pool size")
-	}
-
-	if c.overflow > 0 && c.getSpawnedCount() >= c.overflow {
-		return nil, errors.New("pool is full, can't spawn more workers")
+	case c.queued(p) || c.busy+count > c.capacity(p):
+		// the queue releases the lock
+		if err := c.enqueue(ctx, count, p, clientOf(ctx)); err != nil {
+			return nil, err
+		}
+	default:
+		c.busy += count
+		c.Unlock()
 	}
 
 	var ch = make(chan param, buffSize)
+	var assigned int
 	for i := 0; i < count; i++ {
-		select {
-		case c.workersCh <- ch:
-		default:
-			id := c.incSpawnedCount(1) + c.fixed
-			log.Printf("spawn overflow worker id:%d", id)
-			go c.reader(id, ch, false)
+		if c.assign(ch) {
+			assigned++
+		} else {
+			c.release()
 		}
 	}
+
+	// the pool was stopped while the collection was waiting for workers
+	if assigned == 0 {
+		return nil, ErrPoolStopped
+	}
+
 	return ch, nil
 }
 
-func (c *collector) Collect(ctx context.Context, urls []string, limit int) ([]res, error) {
-	var data = make([]res, 0, len(urls))
+// assign gives the collection to the idle worker or spawns a new one
+func (c *collector) assign(ch chan param) bool {
+	select {
+	case c.workersCh <- ch:
+		return true
+	default:
+	}
 
-	var errCh = make(chan error, limit)
-	var resCh = make(chan res, len(urls))
+	if c.spawn(ch) {
+		return true
+	}
 
-	paramsCh, err := c.acquireWorkers(limit, len(urls))
-	if err != nil {
-		return nil, err
+	// all workers are alive and the slot is reserved, so one of them is about to become idle
+	select {
+	case c.workersCh <- ch:
+		return true
+	case <-c.done:
+		return false
 	}
-	defer close(paramsCh)
+}
 
-	innerCtx, cancel := context.WithCancel(ctx)
-	defer cancel()
+func (c *collector) spawn(ch chan param) bool {
+	c.Lock()
+	if c.workers >= c.fixed+c.overflow {
+		c.Unlock()
+		return false
+	}
+	c.workers++
+	c.lastID++
+	id := c.lastID
+	c.Unlock()
+
+	go c.worker(id, ch)
+
+	return true
+}
+
+func (c *collector) release() {
+	c.Lock()
+	defer c.Unlock()
+	c.busy--
+	c.grant()
+}
+
+// reap retires the idle worker if the pool is above its minimum,
+// workers leave one by one not faster than the shrink interval
+func (c *collector) reap() (bool, time.Duration) {
+	c.Lock()
+	defer c.Unlock()
 
-	for _, url := range urls {
-		paramsCh <- param{ctx: innerCtx, url: url, errCh: errCh, resCh: resCh}
+	if c.workers <= c.min || c.workers <= c.busy {
+		return false, 0
 	}
 
-	for {
+	t := now()
+	if wait := c.reapedAt.Add(c.shrink).Sub(t); wait > 0 {
+		return false, wait
+	}
+
+	c.workers--
+	c.reapedAt = t
+
+	return true, 0
+}
+
+func (c *collector) leave() {
+	c.Lock()
+	defer c.Unlock()
+	c.workers--
+}
+
+func (c *collector) Collect(ctx context.Context, reqs []Request, limit int) ([]Result, error) {
+	return c.collect(ctx, reqs, limit, false)
+}
+
+func (c *collector) CollectPartial(ctx context.Context, reqs []Request, limit int) ([]Result, error) {
+	return c.collect(ctx, reqs, limit, true)
+}
+
+func (c *collector) Stream(ctx context.Context, reqs []Request, limit int) (<-chan Result, error) {
+	if limit <= 0 {
+		return nil, ErrInvalidLimit
+	}
+
+	if err := c.begin(); err != nil {
+		return nil, err
+	}
+
+	// the pool cancels in-flight collections when it's stopped
+	ctx, cancel := context.WithCancel(ctx)
+	go func() {
 		select {
-		case err := <-errCh:
-			return nil, err
+		case <-c.halt:
+			cancel()
 		case <-ctx.Done():
-			return nil, ctx.Err()
-		case result := <-resCh:
-			data = append(data, result)
+		}
+	}()
+
+	var resCh = make(chan Result, len(reqs))
+
+	// fresh cached responses don't need workers
+	var misses = make([]param, 0, len(reqs))
+	for i, req := range reqs {
+		prm := param{ctx: ctx, index: i, req: req, resCh: resCh}
 
-			if len(data) == len(urls) {
-				return data, nil
+		if result, ok := c.lookup(prm); ok {
+			resCh <- result
+			continue
+		}
+
+		misses = append(misses, prm)
+	}
+
+	if len(misses) > 0 {
+		if limit > len(misses) {
+			limit = len(misses)
+		}
+
+		paramsCh, err := c.acquireWorkers(ctx, limit, len(misses))
+		if err != nil {
+			cancel()
+			c.end()
+			return nil, err
+		}
+
+		for _, prm := range misses {
+			paramsCh <- prm
+		}
+		close(paramsCh)
+	}
+
+	// buffered for all urls, so the slow consumer never blocks the forwarding
+	var out = make(chan Result, len(reqs))
+
+	go func() {
+		defer c.end()
+		defer cancel()
+		defer close(out)
+
+		var delivered = make([]bool, len(reqs))
+
+		for received := 0; received < len(reqs); received++ {
+			select {
+			case <-ctx.Done():
+				// workers leave the collection, so the rest of urls get the context error as outcome
+				for i, ok := range delivered {
+					if !ok {
+						result := failed(param{index: i, req: reqs[i]}, ctx.Err())
+						c.count(result)
+						out <- result
+					}
+				}
+				return
+			case result := <-resCh:
+				delivered[result.Index] = true
+				c.count(result)
+				out <- result
 			}
 		}
+	}()
+
+	return out, nil
+}
+
+// collect fails fast on the first broken url, unless partial is set,
+// then every url gets its own outcome in the results
+func (c *collector) collect(ctx context.Context, reqs []Request, limit int, partial bool) ([]Result, error) {
+	var data = make([]Result, len(reqs))
+
+	innerCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	results, err := c.Stream(innerCtx, reqs, limit)
+	if err != nil {
+		return nil, err
 	}
+
+	for result := range results {
+		if result.err != nil && !partial {
+			return nil, result.err
+		}
+
+		// workers finish in any order, so keep the position of the url in the request
+		data[result.Index] = result
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	return data, nil
 }
@@ -2,10 +2,14 @@ package collector
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io/ioutil"
+	"math/rand"
 	"net/http"
 	"net/http/httptest"
 	"reflect"
+	"runtime"
 	"testing"
 	"time"
 )
@@ -17,11 +21,11 @@ func Test_collector_Collect(t *testing.T) {
 	type fields struct {
 		fixed    int
 		overflow int
-		spawned  int
+		busy     int
 	}
 	type args struct {
 		ctx   context.Context
-		urls  []string
+		urls  []Request
 		limit int
 	}
 	tests := []struct {
@@ -29,8 +33,9 @@ func Test_collector_Collect(t *testing.T) {
 		fields       fields
 		args         args
 		handler      http.HandlerFunc
-		want         []res
+		want         []Result
 		wantErr      bool
+		wantErrIs    error
 		cancelBefore func(cancelFunc context.CancelFunc)
 	}{
 		{
@@ -116,7 +121,7 @@ func Test_collector_Collect(t *testing.T) {
 			fields: fields{
 				fixed:    1,
 				overflow: 4,
-				spawned:  4,
+				busy:     4,
 			},
 			args: args{
 				ctx:   context.Background(),
@@ -184,6 +189,22 @@ func Test_collector_Collect(t *testing.T) {
 				cancelFunc()
 			},
 		},
+		{
+			name: "not positive limit",
+			fields: fields{
+				fixed: 1,
+			},
+			args: args{
+				ctx:   context.Background(),
+				urls:  makeUrls(ts, 2),
+				limit: 0,
+			},
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				fmt.Fprint(w, "some_text")
+			},
+			wantErr:   true,
+			wantErrIs: ErrInvalidLimit,
+		},
 	}
 
 	for _, tt := range tests {
@@ -198,7 +219,7 @@ func Test_collector_Collect(t *testing.T) {
 			}
 
 			c := NewCollector(tt.fields.fixed, tt.fields.overflow, time.Second)
-			c.(*collector).spawned = tt.fields.spawned
+			c.(*collector).busy = tt.fields.busy
 
 			c.Start(ctx)
 
@@ -209,6 +230,11 @@ func Test_collector_Collect(t *testing.T) {
 				t.Errorf("Collect() error = %v, wantErr %v", err, tt.wantErr)
 				return
 			}
+			if tt.wantErrIs != nil && !errors.Is(err, tt.wantErrIs) {
+				t.Errorf("Collect() error = %v, want %v", err, tt.wantErrIs)
+			}
+
+			got = withoutVolatile(got)
 			if !reflect.DeepEqual(got, tt.want) {
 				t.Errorf("Collect() got = %v, want %v", got, tt.want)
 			}
@@ -216,26 +242,486 @@ func Test_collector_Collect(t *testing.T) {
 	}
 }
 
-func withTimeout(ctx context.Context, dur time.Duration) (ret context.Context) {
-	ret, _ = context.WithTimeout(ctx, dur)
-	return
+func Test_collector_CollectPartial(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "some_text")
+	}))
+	defer ts.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	c := NewCollector(2, 0, time.Second)
+	c.Start(ctx)
+
+	urls := append(makeUrls(ts, 4), Request{URL: "http://127.0.0.1:0"}, Request{URL: "::broken-url"})
+
+	got, err := c.CollectPartial(context.Background(), urls, 2)
+	if err != nil {
+		t.Fatalf("CollectPartial() error = %v", err)
+	}
+
+	if len(got) != len(urls) {
+		t.Fatalf("CollectPartial() got %d results, want %d", len(got), len(urls))
+	}
+
+	var ok, failed int
+	for _, r := range got {
+		switch r.Status {
+		case StatusOK:
+			ok++
+			if r.Body != "some_text" || r.Error != "" {
+				t.Errorf("CollectPartial() unexpected success result %+v", r)
+			}
+		case StatusError:
+			failed++
+			if r.Body != "" || r.Error == "" || r.Err() == nil {
+				t.Errorf("CollectPartial() unexpected failed result %+v", r)
+			}
+		}
+	}
+
+	if ok != 4 || failed != 2 {
+		t.Errorf("CollectPartial() ok = %d, failed = %d, want 4 and 2", ok, failed)
+	}
+
+	if _, err := c.Collect(context.Background(), urls, 2); err == nil {
+		t.Errorf("Collect() expected to fail fast on broken url")
+	}
+}
+
+func Test_collector_Collect_order(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(time.Duration(rand.Intn(50)) * time.Millisecond) // random upstream latency
+		fmt.Fprint(w, r.URL.Query().Get("i"))
+	}))
+	defer ts.Close()
+
+	tests := []struct {
+		name     string
+		fixed    int
+		overflow int
+		count    int
+		limit    int
+		partial  bool
+	}{
+		{
+			name:  "single worker",
+			fixed: 1,
+			count: 5,
+			limit: 1,
+		},
+		{
+			name:  "multiple workers",
+			fixed: 4,
+			count: 20,
+			limit: 4,
+		},
+		{
+			name:     "overflow workers",
+			fixed:    2,
+			overflow: 6,
+			count:    20,
+			limit:    8,
+		},
+		{
+			name:    "multiple workers partial",
+			fixed:   4,
+			count:   20,
+			limit:   4,
+			partial: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			c := NewCollector(tt.fixed, tt.overflow, time.Second)
+			c.Start(ctx)
+
+			var urls = make([]Request, tt.count)
+			for i := range urls {
+				urls[i] = Request{URL: fmt.Sprintf("%s?i=%d", ts.URL, i)}
+			}
+
+			collect := c.Collect
+			if tt.partial {
+				collect = c.CollectPartial
+			}
+
+			got, err := collect(context.Background(), urls, tt.limit)
+			if err != nil {
+				t.Fatalf("Collect() error = %v", err)
+			}
+
+			for i, r := range got {
+				if r.Index != i || r.Url != urls[i].URL || r.Body != fmt.Sprint(i) {
+					t.Errorf("Collect() result #%d = %+v, want url %s", i, r, urls[i].URL)
+				}
+			}
+		})
+	}
+}
+
+func Test_collector_Stream(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("slow") != "" {
+			time.Sleep(time.Second / 2)
+		}
+		fmt.Fprint(w, "some_text")
+	}))
+	defer ts.Close()
+
+	tests := []struct {
+		name       string
+		urls       []Request
+		ctx        context.Context
+		wantOk     int
+		wantFailed int
+	}{
+		{
+			name:   "all results delivered",
+			urls:   makeUrls(ts, 6),
+			ctx:    context.Background(),
+			wantOk: 6,
+		},
+		{
+			name:       "slow urls are failed by the context",
+			urls:       append(makeUrls(ts, 2), Request{URL: ts.URL + "?slow=1"}, Request{URL: ts.URL + "?slow=1"}),
+			ctx:        withTimeout(context.Background(), time.Second/4),
+			wantOk:     2,
+			wantFailed: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			c := NewCollector(4, 0, time.Second)
+			c.Start(ctx)
+
+			results, err := c.Stream(tt.ctx, tt.urls, 4)
+			if err != nil {
+				t.Fatalf("Stream() error = %v", err)
+			}
+
+			var seen = make(map[int]bool)
+			var ok, failed int
+
+			for r := range results {
+				if seen[r.Index] {
+					t.Errorf("Stream() result #%d delivered twice", r.Index)
+				}
+				seen[r.Index] = true
+
+				if r.Status == StatusOK {
+					ok++
+				} else {
+					failed++
+				}
+			}
+
+			if ok != tt.wantOk || failed != tt.wantFailed {
+				t.Errorf("Stream() ok = %d, failed = %d, want %d and %d", ok, failed, tt.wantOk, tt.wantFailed)
+			}
+		})
+	}
+}
+
+func Test_collector_Collect_request(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		fmt.Fprintf(w, "%s %s %s", r.Method, r.Header.Get("Authorization"), body)
+	}))
+	defer ts.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	c := NewCollector(2, 0, time.Second)
+	c.Start(ctx)
+
+	reqs := []Request{
+		{URL: ts.URL},
+		{URL: ts.URL, Method: http.MethodPost, Header: http.Header{"Authorization": {"Bearer token"}}, Body: `{"id":1}`},
+		{URL: ts.URL, Method: http.MethodDelete},
+	}
+
+	want := []string{
+		"GET  ",
+		`POST Bearer token {"id":1}`,
+		"DELETE  ",
+	}
+
+	got, err := c.Collect(context.Background(), reqs, 2)
+	if err != nil {
+		t.Fatalf("Collect() error = %v", err)
+	}
+
+	for i, r := range got {
+		if r.Body != want[i] {
+			t.Errorf("Collect() result #%d body = %q, want %q", i, r.Body, want[i])
+		}
+	}
+}
+
+func Test_collector_Collect_metadata(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("X-Upstream", "test")
+		w.WriteHeader(http.StatusTeapot)
+		time.Sleep(time.Millisecond * 10)
+		fmt.Fprint(w, `{"ok":false}`)
+	}))
+	defer ts.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	c := NewCollector(1, 0, time.Second)
+	c.Start(ctx)
+
+	got, err := c.Collect(context.Background(), makeUrls(ts, 1), 1)
+	if err != nil {
+		t.Fatalf("Collect() error = %v", err)
+	}
+
+	r := got[0]
+	if r.StatusCode != http.StatusTeapot || r.ContentType != "application/json" || r.Size != 12 || r.Encoding != EncodingJSON {
+		t.Errorf("Collect() got metadata %+v", r)
+	}
+	if r.Header.Get("X-Upstream") != "test" {
+		t.Errorf("Collect() got header %v", r.Header)
+	}
+	if r.TTFB <= 0 || r.Duration < r.TTFB {
+		t.Errorf("Collect() got ttfb = %v, duration = %v", r.TTFB, r.Duration)
+	}
+}
+
+func Test_collector_reap(t *testing.T) {
+	var start = time.Now()
+	defer func() { now = time.Now }()
+
+	tests := []struct {
+		name      string
+		workers   int
+		busy      int
+		reapedAt  time.Duration // offset from the start
+		at        time.Duration
+		want      bool
+		wantRetry time.Duration
+	}{
+		{
+			name:    "pool at its minimum",
+			workers: 2,
+			at:      time.Minute,
+		},
+		{
+			name:    "all workers are acquired",
+			workers: 4,
+			busy:    4,
+			at:      time.Minute,
+		},
+		{
+			name:     "extra worker is reaped",
+			workers:  4,
+			busy:     1,
+			reapedAt: 0,
+			at:       time.Second,
+			want:     true,
+		},
+		{
+			name:      "shrink interval isn't passed",
+			workers:   4,
+			reapedAt:  0,
+			at:        time.Millisecond * 300,
+			wantRetry: time.Millisecond * 700,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewCollector(2, 4, time.Second, WithIdleTimeout(time.Second, time.Second)).(*collector)
+			c.workers = tt.workers
+			c.busy = tt.busy
+			c.reapedAt = start.Add(tt.reapedAt)
+			now = func() time.Time { return start.Add(tt.at) }
+
+			got, retry := c.reap()
+			if got != tt.want || retry != tt.wantRetry {
+				t.Errorf("reap() = %v, %v, want %v, %v", got, retry, tt.want, tt.wantRetry)
+			}
+
+			var wantWorkers = tt.workers
+			if tt.want {
+				wantWorkers--
+			}
+			if c.workers != wantWorkers {
+				t.Errorf("reap() left %d workers, want %d", c.workers, wantWorkers)
+			}
+		})
+	}
+}
+
+func Test_collector_elastic(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(time.Millisecond * 50)
+		fmt.Fprint(w, "OK")
+	}))
+	defer ts.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var base = runtime.NumGoroutine()
+
+	c := NewCollector(2, 6, time.Second, WithMinWorkers(1), WithIdleTimeout(time.Millisecond*50, time.Millisecond*20))
+	// connections aren't kept alive, so only workers stay after the burst
+	c.(*collector).client.Transport = &http.Transport{DisableKeepAlives: true}
+	c.Start(ctx)
+
+	if _, err := c.Collect(context.Background(), makeUrls(ts, 8), 8); err != nil {
+		t.Fatalf("Collect() error = %v", err)
+	}
+
+	c.(*collector).RLock()
+	workers := c.(*collector).workers
+	c.(*collector).RUnlock()
+
+	if workers != 8 {
+		t.Errorf("Collect() spawned %d workers, want 8", workers)
+	}
+
+	// min worker and the watcher of the pool context
+	shrunk := eventually(time.Second*2, func() bool {
+		c.(*collector).RLock()
+		workers = c.(*collector).workers
+		c.(*collector).RUnlock()
+		return workers == 1 && runtime.NumGoroutine() <= base+2
+	})
+	if !shrunk {
+		t.Errorf("pool didn't shrink, workers = %d, goroutines = %d, want 1, %d", workers, runtime.NumGoroutine(), base+2)
+	}
+
+	cancel()
+
+	if !eventually(time.Second, func() bool { return runtime.NumGoroutine() <= base }) {
+		t.Errorf("pool didn't stop, goroutines = %d, want %d", runtime.NumGoroutine(), base)
+	}
+}
+
+func eventually(timeout time.Duration, cond func() bool) bool {
+	for deadline := time.Now().Add(timeout); time.Now().Before(deadline); time.Sleep(time.Millisecond * 10) {
+		if cond() {
+			return true
+		}
+	}
+	return false
+}
+
+func Test_collector_Shutdown(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(time.Millisecond * 200)
+		fmt.Fprint(w, "OK")
+	}))
+	defer ts.Close()
+
+	tests := []struct {
+		name       string
+		deadline   time.Duration
+		wantErr    error
+		wantStatus Status
+	}{
+		{
+			name:       "in-flight collection is drained",
+			deadline:   time.Second,
+			wantStatus: StatusOK,
+		},
+		{
+			name:       "in-flight collection is canceled on deadline",
+			deadline:   time.Millisecond * 50,
+			wantErr:    context.DeadlineExceeded,
+			wantStatus: StatusError,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			c := NewCollector(2, 0, time.Second)
+			c.Start(ctx)
+
+			results, err := c.Stream(context.Background(), makeUrls(ts, 2), 2)
+			if err != nil {
+				t.Fatalf("Stream() error = %v", err)
+			}
+
+			shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), tt.deadline)
+			defer shutdownCancel()
+
+			if err := c.Shutdown(shutdownCtx); err != tt.wantErr {
+				t.Errorf("Shutdown() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			for result := range results {
+				if result.Status != tt.wantStatus {
+					t.Errorf("Stream() result #%d status = %v, want %v", result.Index, result.Status, tt.wantStatus)
+				}
+			}
+
+			if _, err := c.Stream(context.Background(), makeUrls(ts, 1), 1); err != ErrPoolStopped {
+				t.Errorf("Stream() after shutdown error = %v, want %v", err, ErrPoolStopped)
+			}
+		})
+	}
+}
+
+func withTimeout(ctx context.Context, dur time.Duration) context.Context {
+	ctx, cancel := context.WithTimeout(ctx, dur)
+	time.AfterFunc(dur, cancel)
+	return ctx
+}
+
+// withoutVolatile drops metadata which is different for each run
+func withoutVolatile(ress []Result) []Result {
+	for i := range ress {
+		ress[i].Header = nil
+		ress[i].TTFB = 0
+		ress[i].Duration = 0
+	}
+	return ress
 }
 
-func makeRes(ts *httptest.Server, msg string, count int) []res {
-	var ress = make([]res, count)
+func makeRes(ts *httptest.Server, msg string, count int) []Result {
+	var ress = make([]Result, count)
 
 	for i := 0; i < count; i++ {
-		ress[i] = res{Url: ts.URL, Body: msg}
+		ress[i] = Result{
+			Index:       i,
+			Url:         ts.URL,
+			Body:        msg,
+			Encoding:    EncodingText,
+			Charset:     "utf-8",
+			Status:      StatusOK,
+			StatusCode:  http.StatusOK,
+			ContentType: "text/plain; charset=utf-8",
+			Size:        int64(len(msg)),
+			Attempts:    1,
+		}
 	}
 
 	return ress
 }
 
-func makeUrls(ts *httptest.Server, count int) []string {
-	var urls = make([]string, count)
+func makeUrls(ts *httptest.Server, count int) []Request {
+	var urls = make([]Request, count)
 
 	for i := 0; i < count; i++ {
-		urls[i] = ts.URL
+		urls[i] = Request{URL: ts.URL}
 	}
 
 	return urls
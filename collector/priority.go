@@ -0,0 +1,47 @@
+package collector
+
+import "context"
+
+type Priority string
+
+const (
+	PriorityInteractive Priority = "interactive" // gets workers first and is the last to queue
+	PriorityBatch       Priority = "batch"       // queues when reserved workers are left only and is shed first
+)
+
+func (p Priority) Valid() bool {
+	switch p {
+	case PriorityInteractive, PriorityBatch:
+		return true
+	}
+	return false
+}
+
+type priorityKey struct{}
+
+// WithPriority tags collections of the context with the priority, they are interactive by default
+func WithPriority(ctx context.Context, p Priority) context.Context {
+	return context.WithValue(ctx, priorityKey{}, p)
+}
+
+func priorityOf(ctx context.Context) Priority {
+	if p, ok := ctx.Value(priorityKey{}).(Priority); ok && p.Valid() {
+		return p
+	}
+	return PriorityInteractive
+}
+
+// WithPriorityReserve keeps the number of workers for interactive collections, batch ones never take them
+func WithPriorityReserve(workers int) Option {
+	return func(c *collector) {
+		c.reserve = workers
+	}
+}
+
+// capacity is number of workers which collections of the priority can take
+func (c *collector) capacity(p Priority) int {
+	if p == PriorityBatch {
+		return c.fixed + c.overflow - c.reserve
+	}
+	return c.fixed + c.overflow
+}
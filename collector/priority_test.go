@@ -0,0 +1,143 @@
+package collector
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func Test_collector_acquireWorkers_priority(t *testing.T) {
+	tests := []struct {
+		name      string
+		busy      int
+		queued    []Priority // collections of two workers waiting before the acquisition
+		priority  Priority
+		wantErr   error
+		wantShed  int  // index of queued collection which is shed, -1 if none
+		wantQueue bool // the acquisition waits in the queue
+	}{
+		{
+			name:     "interactive takes reserved workers",
+			busy:     2,
+			priority: PriorityInteractive,
+			wantShed: -1,
+		},
+		{
+			name:      "batch doesn't take reserved workers",
+			busy:      2,
+			priority:  PriorityBatch,
+			wantShed:  -1,
+			wantQueue: true,
+		},
+		{
+			name:     "interactive overtakes queued batch",
+			busy:     2,
+			queued:   []Priority{PriorityBatch},
+			priority: PriorityInteractive,
+			wantShed: -1,
+		},
+		{
+			name:      "interactive doesn't overtake queued interactive",
+			busy:      3,
+			queued:    []Priority{PriorityInteractive},
+			priority:  PriorityInteractive,
+			wantShed:  -1,
+			wantQueue: true,
+		},
+		{
+			name:      "interactive sheds latest batch from full queue",
+			busy:      4,
+			queued:    []Priority{PriorityBatch, PriorityInteractive, PriorityBatch},
+			priority:  PriorityInteractive,
+			wantShed:  2,
+			wantQueue: true,
+		},
+		{
+			name:     "batch is rejected by full queue",
+			busy:     4,
+			queued:   []Priority{PriorityBatch, PriorityInteractive, PriorityBatch},
+			priority: PriorityBatch,
+			wantErr:  ErrQueueFull,
+			wantShed: -1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewCollector(2, 2, time.Second, WithWaitQueue(3, time.Millisecond*50), WithPriorityReserve(2)).(*collector)
+			c.busy = tt.busy
+
+			var waiters = make([]*waiter, len(tt.queued))
+			for i, p := range tt.queued {
+				waiters[i] = &waiter{count: 2, priority: p, ready: make(chan struct{})}
+				c.push(waiters[i])
+			}
+
+			start := time.Now()
+			ch, err := c.acquireWorkers(WithPriority(context.Background(), tt.priority), 1, 1)
+			if err == nil {
+				close(ch)
+			}
+
+			wantErr := tt.wantErr
+			if tt.wantQueue {
+				wantErr = ErrPoolFull // the max wait is over, since workers aren't released
+			}
+			if err != wantErr {
+				t.Fatalf("acquireWorkers() error = %v, wantErr %v", err, wantErr)
+			}
+			if queued := time.Since(start) >= time.Millisecond*50; queued != tt.wantQueue {
+				t.Errorf("acquireWorkers() queued = %v, want %v", queued, tt.wantQueue)
+			}
+
+			for i, w := range waiters {
+				select {
+				case <-w.ready:
+					if i != tt.wantShed || w.err != ErrQueueFull {
+						t.Errorf("waiter #%d is released with %v", i, w.err)
+					}
+				default:
+					if i == tt.wantShed {
+						t.Errorf("waiter #%d isn't shed", i)
+					}
+				}
+			}
+		})
+	}
+}
+
+func Test_collector_grant_priority(t *testing.T) {
+	c := NewCollector(2, 2, time.Second, WithWaitQueue(4, time.Second), WithPriorityReserve(1)).(*collector)
+	c.busy = 4
+
+	var queued = []Priority{PriorityBatch, PriorityInteractive, PriorityBatch, PriorityInteractive}
+	var waiters = make([]*waiter, len(queued))
+	for i, p := range queued {
+		waiters[i] = &waiter{count: 1, priority: p, ready: make(chan struct{})}
+		c.push(waiters[i])
+	}
+
+	// interactive ones go first, the last worker is reserved, so batch ones wait for two released workers
+	var want = [][]int{{1}, {3}, {}, {0}, {2}}
+
+	var granted = make(map[int]bool)
+	for step, ready := range want {
+		c.release()
+
+		for _, i := range ready {
+			granted[i] = true
+		}
+
+		for i, w := range waiters {
+			select {
+			case <-w.ready:
+				if !granted[i] {
+					t.Errorf("release #%d: waiter #%d is granted too early", step, i)
+				}
+			default:
+				if granted[i] {
+					t.Errorf("release #%d: waiter #%d isn't granted", step, i)
+				}
+			}
+		}
+	}
+}
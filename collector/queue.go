@@ -0,0 +1,134 @@
+package collector
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"time"
+)
+
+var (
+	ErrPoolStopped = errors.New("can't acquire workers from stopped pool")
+	ErrPoolFull    = errors.New("pool is full, can't spawn more workers")
+	ErrQueueFull   = errors.New("pool is full and wait queue is full")
+)
+
+// WithWaitQueue lets up to size collections wait for workers of the full pool in FIFO order,
+// each no longer than maxWait and its own context, by default they are rejected immediately
+func WithWaitQueue(size int, maxWait time.Duration) Option {
+	return func(c *collector) {
+		c.queueSize = size
+		c.maxWait = maxWait
+	}
+}
+
+type waiter struct {
+	count    int
+	priority Priority
+	client   string
+	ready    chan struct{} // closed when workers are reserved for the waiter or it's shed
+	err      error         // reason of shedding
+	own      *list.Element // element in the queue of its client
+}
+
+// enqueue holds the collection until its workers are reserved, it's called under the lock and returns with it released
+func (c *collector) enqueue(ctx context.Context, count int, p Priority, client string) error {
+	if c.queue.Len() >= c.queueSize && !(p == PriorityInteractive && c.shed()) {
+		c.stats.Rejected++
+		c.Unlock()
+		if c.queueSize == 0 {
+			return ErrPoolFull
+		}
+		return ErrQueueFull
+	}
+
+	w := &waiter{count: count, priority: p, client: client, ready: make(chan struct{})}
+	elem := c.push(w)
+	c.Unlock()
+
+	var timeout <-chan time.Time
+	if c.maxWait > 0 {
+		timer := time.NewTimer(c.maxWait)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	var err error
+	select {
+	case <-w.ready:
+		return w.err
+	case <-ctx.Done():
+		err = ctx.Err()
+	case <-timeout:
+		err = ErrPoolFull
+	case <-c.done:
+		err = ErrPoolStopped
+	}
+
+	c.Lock()
+	defer c.Unlock()
+
+	select {
+	case <-w.ready:
+		if w.err != nil {
+			return w.err
+		}
+		// workers were reserved at the same moment, give them back
+		c.busy -= count
+	default:
+		c.remove(elem)
+	}
+	c.stats.Rejected++
+	c.grant()
+
+	return err
+}
+
+// shed drops the latest batch collection from the full queue to give its place to the interactive one
+func (c *collector) shed() bool {
+	for elem := c.queue.Back(); elem != nil; elem = elem.Prev() {
+		w := elem.Value.(*waiter)
+		if w.priority != PriorityBatch {
+			continue
+		}
+
+		c.remove(elem)
+		c.stats.Rejected++
+		w.err = ErrQueueFull
+		close(w.ready)
+
+		return true
+	}
+	return false
+}
+
+// queued tells if collections of the same or higher priority are already waiting, it's called under the lock
+func (c *collector) queued(p Priority) bool {
+	for elem := c.queue.Front(); elem != nil; elem = elem.Next() {
+		if p == PriorityBatch || elem.Value.(*waiter).priority == PriorityInteractive {
+			return true
+		}
+	}
+	return false
+}
+
+// grant reserves workers for queued collections, interactive ones go first and clients take turns in each class,
+// it's called under the lock
+func (c *collector) grant() {
+	for {
+		elem := c.next()
+		if elem == nil {
+			return
+		}
+
+		w := elem.Value.(*waiter)
+		if c.busy+w.count > c.capacity(w.priority) {
+			return
+		}
+
+		c.busy += w.count
+		c.fair.charge(w)
+		c.remove(elem)
+		close(w.ready)
+	}
+}
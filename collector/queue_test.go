@@ -0,0 +1,142 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func Test_collector_acquireWorkers_queue(t *testing.T) {
+	tests := []struct {
+		name      string
+		queueSize int
+		maxWait   time.Duration
+		queued    int           // collections already waiting in the queue
+		ctxTmt    time.Duration // zero for no deadline
+		releaseIn time.Duration // zero if workers aren't released
+		wantErr   error
+	}{
+		{
+			name:    "no queue",
+			wantErr: ErrPoolFull,
+		},
+		{
+			name:      "queue is full",
+			queueSize: 2,
+			maxWait:   time.Second,
+			queued:    2,
+			wantErr:   ErrQueueFull,
+		},
+		{
+			name:      "workers are released while waiting",
+			queueSize: 2,
+			maxWait:   time.Second,
+			releaseIn: time.Millisecond * 20,
+		},
+		{
+			name:      "max wait is over",
+			queueSize: 2,
+			maxWait:   time.Millisecond * 20,
+			wantErr:   ErrPoolFull,
+		},
+		{
+			name:      "context is done before max wait",
+			queueSize: 2,
+			maxWait:   time.Second,
+			ctxTmt:    time.Millisecond * 20,
+			wantErr:   context.DeadlineExceeded,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewCollector(1, 1, time.Second, WithWaitQueue(tt.queueSize, tt.maxWait)).(*collector)
+			c.busy = 2 // the pool is saturated
+
+			for i := 0; i < tt.queued; i++ {
+				c.push(&waiter{count: 1, ready: make(chan struct{})})
+			}
+
+			if tt.releaseIn > 0 {
+				time.AfterFunc(tt.releaseIn, c.release)
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			if tt.ctxTmt > 0 {
+				ctx, cancel = context.WithTimeout(context.Background(), tt.ctxTmt)
+			}
+			defer cancel()
+
+			ch, err := c.acquireWorkers(ctx, 1, 1)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("acquireWorkers() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil {
+				close(ch)
+			}
+
+			c.Lock()
+			defer c.Unlock()
+			if c.queue.Len() != tt.queued {
+				t.Errorf("acquireWorkers() left %d waiters, want %d", c.queue.Len(), tt.queued)
+			}
+		})
+	}
+}
+
+func Test_collector_grant_fifo(t *testing.T) {
+	c := NewCollector(1, 1, time.Second, WithWaitQueue(4, time.Second)).(*collector)
+	c.busy = 2
+
+	var waiters []*waiter
+	for _, count := range []int{2, 1, 1} {
+		w := &waiter{count: count, priority: PriorityInteractive, ready: make(chan struct{})}
+		waiters = append(waiters, w)
+		c.push(w)
+	}
+
+	// each release frees a single worker of the pool of 2, the outcome is checked under the lock
+	tests := []struct {
+		name      string
+		wantReady []bool
+	}{
+		{
+			name:      "the big collection at the head isn't overtaken by the small ones",
+			wantReady: []bool{false, false, false},
+		},
+		{
+			name:      "the head gets its workers",
+			wantReady: []bool{true, false, false},
+		},
+		{
+			name:      "the next one gets a freed worker",
+			wantReady: []bool{true, true, false},
+		},
+		{
+			name:      "the last one gets a freed worker",
+			wantReady: []bool{true, true, true},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c.release()
+
+			c.Lock()
+			defer c.Unlock()
+
+			for i, w := range waiters {
+				var ready bool
+				select {
+				case <-w.ready:
+					ready = true
+				default:
+				}
+
+				if ready != tt.wantReady[i] {
+					t.Errorf("waiter #%d ready = %v, want %v", i, ready, tt.wantReady[i])
+				}
+			}
+		})
+	}
+}
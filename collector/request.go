@@ -0,0 +1,33 @@
+package collector
+
+import (
+	"io"
+	"net/http"
+	"strings"
+)
+
+type Request struct {
+	URL    string
+	Method string // GET if empty
+	Header http.Header
+	Body   string
+
+	MaxBodySize int64      // collector's limit if zero
+	BodyPolicy  BodyPolicy // collector's policy if empty
+	MaxAttempts int        // attempts of the collector's retry policy if zero
+	Cache       CacheMode  // CachePrefer if empty
+}
+
+func (r Request) method() string {
+	if r.Method == "" {
+		return http.MethodGet
+	}
+	return r.Method
+}
+
+func (r Request) body() io.Reader {
+	if r.Body == "" {
+		return nil
+	}
+	return strings.NewReader(r.Body)
+}
@@ -0,0 +1,59 @@
+package collector
+
+import (
+	"net/http"
+	"time"
+)
+
+type Status string
+
+const (
+	StatusOK    Status = "ok"
+	StatusError Status = "error"
+)
+
+type Result struct {
+	Index  int
+	Url    string
+	Body   string
+	Status Status
+	Error  string `json:",omitempty"`
+
+	Encoding string `json:",omitempty"` // how the body is represented: text, json or base64
+	Charset  string `json:",omitempty"` // original charset of the text body
+
+	BodyLimit BodyPolicy `json:",omitempty"` // applied policy when the body exceeded the size limit
+	Hash      string     `json:",omitempty"` // sha256 of the whole body for the hash policy
+
+	// response metadata, it's filled as far as the response was received
+	StatusCode  int           `json:",omitempty"`
+	Header      http.Header   `json:",omitempty"`
+	ContentType string        `json:",omitempty"`
+	Size        int64         `json:",omitempty"` // body length in bytes
+	TTFB        time.Duration `json:",omitempty"` // time to first response byte
+	Duration    time.Duration `json:",omitempty"` // total time of the fetch including the body reading
+	Attempts    int           `json:",omitempty"` // number of made attempts including retries
+	Cache       CacheStatus   `json:",omitempty"` // empty if the cache wasn't used
+	Shared      bool          `json:",omitempty"` // result of the fetch made by another identical request
+	Hedged      bool          `json:",omitempty"` // the second identical request was sent to cut the latency
+
+	err error
+	raw []byte // original body bytes
+}
+
+func (r Result) Err() error {
+	return r.err
+}
+
+func (r *Result) fail(err error) {
+	r.Body = ""
+	r.Status = StatusError
+	r.Error = err.Error()
+	r.err = err
+}
+
+func failed(prm param, err error) Result {
+	var result = Result{Index: prm.index, Url: prm.req.URL}
+	result.fail(err)
+	return result
+}
@@ -0,0 +1,153 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+var jitter = rand.Float64
+
+var DefaultRetryStatus = []int{
+	http.StatusTooManyRequests,
+	http.StatusBadGateway,
+	http.StatusServiceUnavailable,
+	http.StatusGatewayTimeout,
+}
+
+type RetryPolicy struct {
+	MaxAttempts int           // all attempts including the first one, no retries if less than 2
+	BaseDelay   time.Duration // delay before the first retry, it's doubled for each next one
+	MaxDelay    time.Duration // upper bound of the delay, unbounded if zero
+	Jitter      float64       // fraction of the delay which is randomized, from 0 to 1
+	RetryStatus []int         // status codes to retry, DefaultRetryStatus if empty
+
+	// RetryError reports whether the error is transient, retryableError is used if nil
+	RetryError func(err error) bool
+}
+
+// WithRetryPolicy sets the retry policy of each url, requests can change number of attempts
+func WithRetryPolicy(policy RetryPolicy) Option {
+	return func(c *collector) {
+		c.retry = policy
+	}
+}
+
+func (p RetryPolicy) attempts(req Request) int {
+	if req.MaxAttempts > 0 {
+		return req.MaxAttempts
+	}
+	if p.MaxAttempts > 0 {
+		return p.MaxAttempts
+	}
+	return 1
+}
+
+// retryable reports whether the result is transient, requests of not idempotent methods
+// are retried only if they ask for attempts by themselves
+func (p RetryPolicy) retryable(req Request, result Result) bool {
+	if !idempotent(req) && req.MaxAttempts == 0 {
+		return false
+	}
+
+	if result.err != nil {
+		if p.RetryError != nil {
+			return p.RetryError(result.err)
+		}
+		return retryableError(result.err)
+	}
+
+	var statuses = p.RetryStatus
+	if len(statuses) == 0 {
+		statuses = DefaultRetryStatus
+	}
+
+	for _, status := range statuses {
+		if result.StatusCode == status {
+			return true
+		}
+	}
+
+	return false
+}
+
+func idempotent(r Request) bool {
+	switch r.method() {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// backoff is exponential delay of the attempt with the jitter applied,
+// the delay asked by upstream in Retry-After is respected if it's longer,
+// there is no retry if upstream asks to wait over the max delay
+func (p RetryPolicy) backoff(attempt int, result Result) (time.Duration, bool) {
+	delay := float64(p.BaseDelay) * math.Pow(2, float64(attempt-1))
+	if p.MaxDelay > 0 && delay > float64(p.MaxDelay) {
+		delay = float64(p.MaxDelay)
+	}
+
+	delay -= delay * p.Jitter * jitter()
+
+	if after := retryAfter(result.Header); after > time.Duration(delay) {
+		if p.MaxDelay > 0 && after > p.MaxDelay {
+			return 0, false
+		}
+		return after, true
+	}
+
+	return time.Duration(delay), true
+}
+
+func retryAfter(header http.Header) time.Duration {
+	value := header.Get("Retry-After")
+	if value == "" {
+		return 0
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return time.Duration(seconds) * time.Second
+	}
+
+	if date, err := http.ParseTime(value); err == nil {
+		return time.Until(date)
+	}
+
+	return 0
+}
+
+func retryableError(err error) bool {
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+
+	// other network errors like unknown host won't go away on retry
+	return errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.ECONNRESET)
+}
+
+// sleep waits for the delay unless the context ends earlier or its deadline comes before the delay is over
+func sleep(ctx context.Context, delay time.Duration) bool {
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < delay {
+		return false
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
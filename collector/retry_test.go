@@ -0,0 +1,263 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestRetryPolicy_backoff(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  RetryPolicy
+		attempt int
+		jitter  float64
+		header  http.Header
+		want    time.Duration
+		wantOk  bool
+	}{
+		{
+			name:    "first retry",
+			policy:  RetryPolicy{BaseDelay: time.Millisecond * 100},
+			attempt: 1,
+			want:    time.Millisecond * 100,
+			wantOk:  true,
+		},
+		{
+			name:    "exponential grow",
+			policy:  RetryPolicy{BaseDelay: time.Millisecond * 100},
+			attempt: 4,
+			want:    time.Millisecond * 800,
+			wantOk:  true,
+		},
+		{
+			name:    "bounded by max delay",
+			policy:  RetryPolicy{BaseDelay: time.Millisecond * 100, MaxDelay: time.Millisecond * 300},
+			attempt: 4,
+			want:    time.Millisecond * 300,
+			wantOk:  true,
+		},
+		{
+			name:    "with jitter",
+			policy:  RetryPolicy{BaseDelay: time.Millisecond * 100, Jitter: 0.5},
+			attempt: 2,
+			jitter:  0.5,
+			want:    time.Millisecond * 150, // 200 - 200*0.5*0.5
+			wantOk:  true,
+		},
+		{
+			name:    "longer retry-after",
+			policy:  RetryPolicy{BaseDelay: time.Millisecond * 100},
+			attempt: 1,
+			header:  http.Header{"Retry-After": {"2"}},
+			want:    time.Second * 2,
+			wantOk:  true,
+		},
+		{
+			name:    "shorter retry-after",
+			policy:  RetryPolicy{BaseDelay: time.Second * 3},
+			attempt: 1,
+			header:  http.Header{"Retry-After": {"2"}},
+			want:    time.Second * 3,
+			wantOk:  true,
+		},
+		{
+			name:    "retry-after over max delay",
+			policy:  RetryPolicy{BaseDelay: time.Millisecond * 100, MaxDelay: time.Second},
+			attempt: 1,
+			header:  http.Header{"Retry-After": {"3600"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			jitter = func() float64 { return tt.jitter } // mock random function
+
+			got, ok := tt.policy.backoff(tt.attempt, Result{Header: tt.header})
+			if got != tt.want || ok != tt.wantOk {
+				t.Errorf("backoff() = %v, %v, want %v, %v", got, ok, tt.want, tt.wantOk)
+			}
+		})
+	}
+}
+
+func Test_collector_fetch_retry(t *testing.T) {
+	var hits int32
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&hits, 1)
+
+		switch r.URL.Query().Get("mode") {
+		case "unavailable":
+			if n <= 2 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+		case "throttled":
+			w.Header().Set("Retry-After", "10")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		case "not-found":
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		fmt.Fprint(w, "some_text")
+	}))
+	defer ts.Close()
+
+	tests := []struct {
+		name         string
+		url          string
+		method       string
+		ctx          context.Context
+		maxAttempts  int
+		maxDelay     time.Duration
+		reqAttempts  int // attempts asked by the request
+		wantAttempts int
+		wantStatus   int
+		wantErr      bool
+	}{
+		{
+			name:         "recovered after retries",
+			url:          ts.URL + "?mode=unavailable",
+			ctx:          context.Background(),
+			maxAttempts:  3,
+			wantAttempts: 3,
+			wantStatus:   http.StatusOK,
+		},
+		{
+			name:         "out of attempts",
+			url:          ts.URL + "?mode=unavailable",
+			ctx:          context.Background(),
+			maxAttempts:  2,
+			wantAttempts: 2,
+			wantStatus:   http.StatusServiceUnavailable,
+		},
+		{
+			name:         "not retryable status",
+			url:          ts.URL + "?mode=not-found",
+			ctx:          context.Background(),
+			maxAttempts:  3,
+			wantAttempts: 1,
+			wantStatus:   http.StatusNotFound,
+		},
+		{
+			name:         "retry-after is beyond the deadline",
+			url:          ts.URL + "?mode=throttled",
+			ctx:          withTimeout(context.Background(), time.Second),
+			maxAttempts:  3,
+			wantAttempts: 1,
+			wantStatus:   http.StatusTooManyRequests,
+		},
+		{
+			name:         "retry-after is over max delay",
+			url:          ts.URL + "?mode=throttled",
+			ctx:          context.Background(),
+			maxAttempts:  3,
+			maxDelay:     time.Second,
+			wantAttempts: 1,
+			wantStatus:   http.StatusTooManyRequests,
+		},
+		{
+			name:         "not idempotent method",
+			url:          ts.URL + "?mode=unavailable",
+			method:       http.MethodPost,
+			ctx:          context.Background(),
+			maxAttempts:  3,
+			wantAttempts: 1,
+			wantStatus:   http.StatusServiceUnavailable,
+		},
+		{
+			name:         "not idempotent method asks for retries",
+			url:          ts.URL + "?mode=unavailable",
+			method:       http.MethodPost,
+			ctx:          context.Background(),
+			reqAttempts:  3,
+			wantAttempts: 3,
+			wantStatus:   http.StatusOK,
+		},
+		{
+			name:         "retry connection errors",
+			url:          "http://127.0.0.1:0",
+			ctx:          context.Background(),
+			maxAttempts:  3,
+			wantAttempts: 3,
+			wantErr:      true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			atomic.StoreInt32(&hits, 0)
+
+			c := NewCollector(1, 0, time.Second, WithRetryPolicy(RetryPolicy{
+				MaxAttempts: tt.maxAttempts,
+				BaseDelay:   time.Millisecond * 10,
+				MaxDelay:    tt.maxDelay,
+			})).(*collector)
+
+			got := c.fetch(param{ctx: tt.ctx, req: Request{URL: tt.url, Method: tt.method, MaxAttempts: tt.reqAttempts}})
+			if (got.Err() != nil) != tt.wantErr {
+				t.Errorf("fetch() error = %v, wantErr %v", got.Err(), tt.wantErr)
+			}
+			if got.Attempts != tt.wantAttempts || got.StatusCode != tt.wantStatus {
+				t.Errorf("fetch() attempts = %d, status = %d, want %d and %d", got.Attempts, got.StatusCode, tt.wantAttempts, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func Test_retryableError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{
+			name: "connection refused",
+			err:  &net.OpError{Op: "dial", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)},
+			want: true,
+		},
+		{
+			name: "connection reset",
+			err:  &net.OpError{Op: "read", Err: os.NewSyscallError("read", syscall.ECONNRESET)},
+			want: true,
+		},
+		{
+			name: "timeout",
+			err:  &net.DNSError{Err: "i/o timeout", IsTimeout: true},
+			want: true,
+		},
+		{
+			name: "unexpected eof",
+			err:  fmt.Errorf("read body: %w", io.ErrUnexpectedEOF),
+			want: true,
+		},
+		{
+			name: "unknown host",
+			err:  &net.OpError{Op: "dial", Err: &net.DNSError{Err: "no such host", Name: "example.invalid", IsNotFound: true}},
+		},
+		{
+			name: "not network error",
+			err:  errors.New("some error"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := retryableError(tt.err); got != tt.want {
+				t.Errorf("retryableError() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
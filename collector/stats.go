@@ -0,0 +1,90 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+type Stats struct {
+	Workers     int           // alive workers
+	FixedActive int           // fixed workers busy with collections
+	FixedIdle   int           // fixed workers waiting for collections
+	Spawned     int           // overflow workers alive above the fixed ones
+	Rejected    uint64        // acquisitions rejected since the pool is full or stopped
+	InFlight    int           // collections which are being processed
+	Queued      int           // collections waiting for workers
+	MaxWait     time.Duration // the longest wait of the queued collection, zero if it isn't bounded
+	Outcomes    Outcomes      // outcomes of collected urls
+}
+
+type Outcomes struct {
+	OK          uint64
+	Error       uint64
+	Canceled    uint64 // the collection was over before the url was collected
+	CircuitOpen uint64 // short-circuited by the breaker of the host
+}
+
+func (c *collector) Stats() Stats {
+	c.RLock()
+	defer c.RUnlock()
+
+	var stats = c.stats
+	stats.Workers = c.workers
+	stats.Queued = c.queue.Len()
+	stats.MaxWait = c.maxWait
+
+	fixed := minInt(c.workers, c.fixed)
+	stats.FixedActive = minInt(c.busy, fixed)
+	stats.FixedIdle = fixed - stats.FixedActive
+	stats.Spawned = c.workers - fixed
+
+	return stats
+}
+
+func (c *collector) count(result Result) {
+	c.Lock()
+	defer c.Unlock()
+
+	switch {
+	case result.err == nil:
+		c.stats.Outcomes.OK++
+	case errors.Is(result.err, context.Canceled), errors.Is(result.err, context.DeadlineExceeded):
+		c.stats.Outcomes.Canceled++
+	case errors.Is(result.err, ErrCircuitOpen):
+		c.stats.Outcomes.CircuitOpen++
+	default:
+		c.stats.Outcomes.Error++
+	}
+}
+
+// begin registers the collection unless the pool is stopped
+func (c *collector) begin() error {
+	c.Lock()
+	defer c.Unlock()
+
+	if c.closed {
+		c.stats.Rejected++
+		return ErrPoolStopped
+	}
+
+	c.stats.InFlight++
+	c.running.Add(1)
+
+	return nil
+}
+
+func (c *collector) end() {
+	c.Lock()
+	defer c.Unlock()
+
+	c.stats.InFlight--
+	c.running.Done()
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,62 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func Test_collector_Stats(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			time.Sleep(time.Millisecond * 200)
+		}
+		fmt.Fprint(w, "OK")
+	}))
+	defer ts.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	c := NewCollector(2, 1, time.Second)
+	c.Start(ctx)
+
+	if got := c.Stats(); got.Workers != 2 || got.FixedIdle != 2 || got.FixedActive != 0 || got.Spawned != 0 {
+		t.Errorf("Stats() of started pool = %+v", got)
+	}
+
+	slow := []Request{{URL: ts.URL + "/slow"}, {URL: ts.URL + "/slow"}, {URL: ts.URL + "/slow"}}
+
+	results, err := c.Stream(context.Background(), slow, 3)
+	if err != nil {
+		t.Fatalf("Stream() error = %v", err)
+	}
+
+	if _, err := c.Collect(context.Background(), makeUrls(ts, 1), 1); err == nil {
+		t.Errorf("Collect() on full pool, want error")
+	}
+
+	got := c.Stats()
+	if got.Workers != 3 || got.FixedActive != 2 || got.FixedIdle != 0 || got.Spawned != 1 || got.InFlight != 1 || got.Rejected != 1 {
+		t.Errorf("Stats() of busy pool = %+v", got)
+	}
+
+	for range results {
+	}
+
+	if _, err := c.CollectPartial(withTimeout(context.Background(), time.Millisecond*50), slow[:1], 1); err == nil {
+		t.Errorf("CollectPartial() after deadline, want error")
+	}
+
+	// the canceled collection leaves the worker once the slow url is collected
+	time.Sleep(time.Millisecond * 250)
+
+	got = c.Stats()
+	want := Outcomes{OK: 3, Canceled: 1}
+	if got.Outcomes != want || got.InFlight != 0 {
+		t.Errorf("Stats() outcomes = %+v, in flight = %d, want %+v, 0", got.Outcomes, got.InFlight, want)
+	}
+}
@@ -2,72 +2,235 @@ package collector
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"io/ioutil"
+	"io"
 	"log"
 	"net/http"
+	"net/http/httptrace"
+	"net/url"
+	"time"
 )
 
-func (c *collector) fixedWorker(id int, paramsCh <-chan chan param) {
+func (c *collector) worker(id int, ch chan param) {
 	log.Printf("start worker id:%d", id)
 	defer log.Printf("stop worker id:%d", id)
 
+	for {
+		if ch != nil {
+			c.reader(id, ch)
+			c.release()
+		}
+
+		var ok bool
+		if ch, ok = c.wait(); !ok {
+			return
+		}
+	}
+}
+
+// wait blocks the idle worker until the next collection, workers above the minimum are reaped after idle timeout
+func (c *collector) wait() (chan param, bool) {
+	timer := time.NewTimer(c.idle)
+	defer timer.Stop()
+
+	var idle = timer.C
+
 	for {
 		select {
-		case ch, ok := <-paramsCh:
-			if !ok {
-				return
+		case ch := <-c.workersCh:
+			return ch, true
+		case <-c.done:
+			c.leave()
+			return nil, false
+		case <-idle:
+			reaped, retry := c.reap()
+			if reaped {
+				return nil, false
 			}
 
-			c.reader(id, ch, true)
+			if retry > 0 {
+				timer.Reset(retry)
+			} else {
+				idle = nil // the pool is at its minimum, the worker stays
+			}
 		}
 	}
 }
 
-func (c *collector) reader(id int, ch <-chan param, fixed bool) {
+func (c *collector) reader(id int, ch <-chan param) {
 	log.Printf("reader id:%d, acquired", id)
 	defer log.Printf("reader id:%d, released", id)
 
-	if !fixed {
-		defer c.incSpawnedCount(-1)
-	}
-
 	for prm := range ch {
 
 		if isDoneContext(prm.ctx) {
 			break
 		}
 
-		log.Printf("reader id:%d, got:%s", id, prm.url)
+		log.Printf("reader id:%d, got:%s %s", id, prm.req.method(), prm.req.URL)
 
-		req, err := http.NewRequest(http.MethodGet, prm.url, nil)
-		if err != nil {
-			prm.errCh <- err
-			break
-		}
+		prm.resCh <- c.fetch(prm)
+	}
+}
 
-		req = req.WithContext(prm.ctx)
+func (c *collector) fetch(prm param) Result {
+	var result Result
 
-		resp, err := c.client.Do(req)
-		if err != nil {
-			prm.errCh <- fmt.Errorf("%s :%w", prm.url, err)
-			break
+	if key, ok := flightKey(prm.req); ok && c.flights != nil {
+		result = c.flights.do(prm, key, c.fetchOwn)
+	} else {
+		result = c.fetchOwn(prm)
+	}
+
+	result.raw = nil // it's needed only to be stored in the cache
+
+	return result
+}
+
+func (c *collector) fetchOwn(prm param) Result {
+	if c.cache != nil && prm.req.cacheable() {
+		return c.fetchCached(prm)
+	}
+	return c.fetchRetry(prm)
+}
+
+// fetchRetry retries transient failures of the url with respect of the retry policy and the collection context
+func (c *collector) fetchRetry(prm param) Result {
+	var attempts = c.retry.attempts(prm.req)
+	var start = time.Now()
+
+	for attempt := 1; ; attempt++ {
+		result := c.fetchOnce(prm)
+		result.Attempts = attempt
+		result.Duration = time.Since(start)
+
+		if attempt >= attempts || isDoneContext(prm.ctx) || !c.retry.retryable(prm.req, result) {
+			return result
 		}
 
-		bts, err := ioutil.ReadAll(resp.Body)
-		resp.Body.Close()
-		if err != nil {
-			prm.errCh <- fmt.Errorf("%s :%w", prm.url, err)
-			break
+		delay, ok := c.retry.backoff(attempt, result)
+		if !ok {
+			return result
 		}
 
-		prm.resCh <- res{
-			Url:  prm.url,
-			Body: string(bts),
+		log.Printf("retry %s in %s, attempt %d of %d", prm.req.URL, delay, attempt+1, attempts)
+
+		if !sleep(prm.ctx, delay) {
+			return result
 		}
 	}
 }
 
+// fetchOnce makes a single attempt guarded by the circuit breaker of the host
+func (c *collector) fetchOnce(prm param) Result {
+	if c.breakers == nil {
+		return c.doHedged(prm)
+	}
+
+	u, err := url.Parse(prm.req.URL)
+	if err != nil || u.Hostname() == "" {
+		return c.doHedged(prm)
+	}
+
+	b := c.breakers.get(u.Hostname())
+	if !b.allow(now()) {
+		return failed(prm, fmt.Errorf("%s :%w", prm.req.URL, ErrCircuitOpen))
+	}
+
+	result := c.doHedged(prm)
+
+	if isDoneContext(prm.ctx) {
+		b.abort() // the collection is over, it says nothing about upstream
+		return result
+	}
+
+	failure := result.err != nil && !errors.Is(result.err, ErrBodyTooLarge) || result.StatusCode >= http.StatusInternalServerError
+	b.done(now(), failure)
+
+	return result
+}
+
+func (c *collector) do(prm param) Result {
+	var start = time.Now()
+	var ttfb time.Duration
+
+	fetcher, ok := c.fetchers[scheme(prm.req.URL)]
+	if !ok {
+		return failed(prm, fmt.Errorf("%s :%w", prm.req.URL, ErrUnsupportedScheme))
+	}
+
+	trace := &httptrace.ClientTrace{
+		GotFirstResponseByte: func() {
+			ttfb = time.Since(start)
+		},
+	}
+
+	release, err := c.acquireHost(prm.ctx, prm.req.URL)
+	if err != nil {
+		result := failed(prm, fmt.Errorf("%s :%w", prm.req.URL, err))
+		result.Duration = time.Since(start)
+		return result
+	}
+	defer release()
+
+	resp, err := fetcher.Fetch(httptrace.WithClientTrace(prm.ctx, trace), prm.req)
+	if err != nil {
+		result := failed(prm, fmt.Errorf("%s :%w", prm.req.URL, err))
+		result.Duration = time.Since(start)
+		return result
+	}
+
+	// fetchers which aren't http give the whole response at once
+	if ttfb == 0 {
+		ttfb = time.Since(start)
+	}
+
+	result := c.response(prm, resp.StatusCode, resp.Header, resp.Body)
+	resp.Body.Close()
+
+	result.TTFB = ttfb
+	result.Duration = time.Since(start)
+
+	return result
+}
+
+// response makes the result of upstream or cached response with respect of the body limits
+func (c *collector) response(prm param, statusCode int, header http.Header, r io.Reader) Result {
+	limit, policy := c.bodyLimit(prm.req)
+
+	body, err := readBody(r, limit, policy)
+
+	var result = Result{
+		Index:       prm.index,
+		Url:         prm.req.URL,
+		Status:      StatusOK,
+		StatusCode:  statusCode,
+		Header:      header,
+		ContentType: header.Get("Content-Type"),
+		Size:        body.size,
+		BodyLimit:   body.exceeded,
+		Hash:        body.hash,
+	}
+
+	if err != nil {
+		result.fail(fmt.Errorf("%s :%w", prm.req.URL, err))
+		return result
+	}
+
+	if body.exceeded == BodyHash {
+		return result
+	}
+
+	result.Body, result.Encoding, result.Charset = decodeBody(result.ContentType, body.bts)
+
+	if body.exceeded == "" {
+		result.raw = body.bts
+	}
+
+	return result
+}
+
 func isDoneContext(ctx context.Context) bool {
 	select {
 	case <-ctx.Done():
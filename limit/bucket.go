@@ -0,0 +1,123 @@
+package limit
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+type tokenBucket struct {
+	sync.Mutex
+	interval time.Duration // time to refill one token
+	burst    float64
+	tokens   float64
+	last     time.Time // time of the last refill
+}
+
+// NewTokenBucket refills limit tokens each rate up to burst tokens, each request takes one,
+// so idle clients can make a burst of requests, burst is equal to limit if it's zero
+func NewTokenBucket(rate time.Duration, limit, burst int) Limiter {
+	if burst <= 0 {
+		burst = limit
+	}
+
+	return &tokenBucket{
+		interval: rate / time.Duration(limit),
+		burst:    float64(burst),
+		tokens:   float64(burst),
+	}
+}
+
+func (b *tokenBucket) refill(now time.Time) {
+	if !now.After(b.last) {
+		return
+	}
+
+	if !b.last.IsZero() {
+		b.tokens += float64(now.Sub(b.last)) / float64(b.interval)
+		if b.tokens > b.burst {
+			b.tokens = b.burst
+		}
+	}
+
+	b.last = now
+}
+
+func (b *tokenBucket) Allow() bool {
+	return reserveFunc(b.reserve).allowN(1)
+}
+
+func (b *tokenBucket) AllowN(n int) bool {
+	return reserveFunc(b.reserve).allowN(n)
+}
+
+func (b *tokenBucket) Reserve() time.Duration {
+	return reserveFunc(b.reserve).reserve()
+}
+
+func (b *tokenBucket) ReserveN(n int, max time.Duration) (time.Duration, bool) {
+	return reserveFunc(b.reserve).reserveN(n, max)
+}
+
+func (b *tokenBucket) Wait(ctx context.Context) error {
+	return reserveFunc(b.reserve).wait(ctx, b.cancel)
+}
+
+// reserve takes tokens in debt, the debt is the time to wait until they are refilled
+func (b *tokenBucket) reserve(n int64, max time.Duration) (time.Duration, bool) {
+	b.Lock()
+	defer b.Unlock()
+
+	if float64(n) > b.burst {
+		return never, false
+	}
+
+	b.refill(now())
+
+	b.tokens -= float64(n)
+
+	var delay time.Duration
+	if b.tokens < 0 {
+		delay = time.Duration(math.Ceil(-b.tokens * float64(b.interval)))
+	}
+
+	if delay > max {
+		b.tokens += float64(n)
+		return delay, false
+	}
+
+	return delay, true
+}
+
+func (b *tokenBucket) cancel(n int64) {
+	b.Lock()
+	defer b.Unlock()
+
+	b.refill(now())
+
+	b.tokens += float64(n)
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+}
+
+func (b *tokenBucket) State() State {
+	b.Lock()
+	defer b.Unlock()
+
+	b.refill(now())
+
+	var state = State{
+		Limit: int(b.burst),
+		Reset: time.Duration(math.Ceil((b.burst - b.tokens) * float64(b.interval))),
+	}
+
+	if b.tokens >= 1 {
+		state.Remaining = int(b.tokens)
+	} else {
+		state.RetryAfter = time.Duration(math.Ceil((1 - b.tokens) * float64(b.interval)))
+	}
+
+	return state
+}
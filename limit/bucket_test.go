@@ -0,0 +1,178 @@
+package limit
+
+import (
+	"testing"
+	"time"
+)
+
+type step struct {
+	at   time.Duration // offset from the start
+	n    int           // number of requests at the moment
+	want int           // number of allowed requests
+}
+
+func runSteps(t *testing.T, l Limiter, steps []step) {
+	var start = time.Unix(0, time.Second.Nanoseconds())
+
+	for i, s := range steps {
+		now = func() time.Time { return start.Add(s.at) }
+
+		var got int
+		for j := 0; j < s.n; j++ {
+			if l.Allow() {
+				got++
+			}
+		}
+
+		if got != s.want {
+			t.Errorf("step #%d at %s: allowed %d of %d, want %d", i, s.at, got, s.n, s.want)
+		}
+	}
+}
+
+func Test_tokenBucket_Allow(t *testing.T) {
+	tests := []struct {
+		name  string
+		limit int
+		burst int
+		steps []step
+	}{
+		{
+			name:  "full bucket lets burst through",
+			limit: 10,
+			burst: 5,
+			steps: []step{
+				{at: 0, n: 7, want: 5},
+			},
+		},
+		{
+			name:  "burst is equal to limit by default",
+			limit: 10,
+			steps: []step{
+				{at: 0, n: 12, want: 10},
+			},
+		},
+		{
+			name:  "tokens are refilled at the rate",
+			limit: 10,
+			burst: 5,
+			steps: []step{
+				{at: 0, n: 5, want: 5},
+				{at: time.Millisecond * 50, n: 1, want: 0},  // half of token
+				{at: time.Millisecond * 100, n: 1, want: 1}, // one token
+				{at: time.Millisecond * 350, n: 3, want: 2}, // two and half tokens
+			},
+		},
+		{
+			name:  "refill doesn't exceed burst",
+			limit: 10,
+			burst: 5,
+			steps: []step{
+				{at: 0, n: 5, want: 5},
+				{at: time.Minute, n: 10, want: 5},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func() { now = time.Now }()
+			runSteps(t, NewTokenBucket(time.Second, tt.limit, tt.burst), tt.steps)
+		})
+	}
+}
+
+type reservation struct {
+	at        time.Duration // offset from the start
+	allowN    int           // number of requests for AllowN
+	reserveN  int           // number of requests for ReserveN, Reserve is called if neither is set
+	max       time.Duration // max wait of ReserveN
+	wantAllow bool
+	want      time.Duration // delay of Reserve and ReserveN
+}
+
+func runReservations(t *testing.T, l Limiter, reservations []reservation) {
+	var start = time.Unix(0, time.Second.Nanoseconds())
+
+	for i, r := range reservations {
+		now = func() time.Time { return start.Add(r.at) }
+
+		if r.allowN > 0 {
+			if got := l.AllowN(r.allowN); got != r.wantAllow {
+				t.Errorf("#%d at %s: AllowN(%d) = %v, want %v", i, r.at, r.allowN, got, r.wantAllow)
+			}
+			continue
+		}
+
+		if r.reserveN > 0 {
+			got, ok := l.ReserveN(r.reserveN, r.max)
+			if got != r.want || ok != r.wantAllow {
+				t.Errorf("#%d at %s: ReserveN(%d, %s) = %v, %v, want %v, %v", i, r.at, r.reserveN, r.max, got, ok, r.want, r.wantAllow)
+			}
+			continue
+		}
+
+		if got := l.Reserve(); got != r.want {
+			t.Errorf("#%d at %s: Reserve() = %v, want %v", i, r.at, got, r.want)
+		}
+	}
+}
+
+// both algorithms admit bursts of 2 requests and space the rest by 100ms
+var burstReservations = []reservation{
+	{at: 0, allowN: 3, wantAllow: false},
+	{at: 0, allowN: 2, wantAllow: true},
+	{at: 0, want: time.Millisecond * 100},
+	{at: 0, want: time.Millisecond * 200},
+	{at: time.Millisecond * 250, want: time.Millisecond * 50},
+	{at: time.Millisecond * 250, allowN: 1, wantAllow: false},
+	{at: time.Second, allowN: 2, wantAllow: true},
+	{at: time.Second, reserveN: 1, want: time.Millisecond * 100, wantAllow: false},
+	{at: time.Second, reserveN: 2, want: time.Millisecond * 200, wantAllow: false},
+	{at: time.Second, reserveN: 2, max: time.Second, want: time.Millisecond * 200, wantAllow: true},
+	{at: time.Second, want: time.Millisecond * 300},
+	{at: time.Hour, reserveN: 3, max: time.Hour, want: never, wantAllow: false},
+}
+
+func Test_tokenBucket_Reserve(t *testing.T) {
+	defer func() { now = time.Now }()
+	runReservations(t, NewTokenBucket(time.Second, 10, 2), burstReservations)
+}
+
+func Test_tokenBucket_State(t *testing.T) {
+	tests := []struct {
+		name string
+		at   time.Duration // offset from the burst of 5 requests
+		want State
+	}{
+		{
+			name: "empty bucket",
+			want: State{Limit: 5, Remaining: 0, Reset: time.Millisecond * 500, RetryAfter: time.Millisecond * 100},
+		},
+		{
+			name: "partially refilled bucket",
+			at:   time.Millisecond * 150,
+			want: State{Limit: 5, Remaining: 1, Reset: time.Millisecond * 350},
+		},
+		{
+			name: "full bucket",
+			at:   time.Second,
+			want: State{Limit: 5, Remaining: 5},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func() { now = time.Now }()
+
+			var start = time.Unix(0, time.Second.Nanoseconds())
+			now = func() time.Time { return start }
+
+			l := NewTokenBucket(time.Second, 10, 5)
+			l.AllowN(5)
+
+			now = func() time.Time { return start.Add(tt.at) }
+			if got := l.State(); got != tt.want {
+				t.Errorf("State() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
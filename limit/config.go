@@ -0,0 +1,39 @@
+package limit
+
+import (
+	"fmt"
+	"time"
+)
+
+type Algorithm string
+
+const (
+	SlidingWindow Algorithm = "sliding-window" // approximated sliding window counter
+	TokenBucket   Algorithm = "token-bucket"   // refilled bucket of tokens, tolerates bursts of idle clients
+	GCRA          Algorithm = "gcra"           // generic cell rate algorithm, evenly spaced requests with bursts
+)
+
+type Config struct {
+	Algorithm Algorithm     // sliding window if empty
+	Rate      time.Duration // window of the limit
+	Limit     int           // requests per rate
+	Burst     int           // requests in a burst for token bucket and gcra, equal to limit if zero
+}
+
+// New makes the limiter of the algorithm chosen in the config
+func New(cfg Config) (Limiter, error) {
+	if cfg.Rate <= 0 || cfg.Limit <= 0 {
+		return nil, fmt.Errorf("rate and limit should be positive, got %s and %d", cfg.Rate, cfg.Limit)
+	}
+
+	switch cfg.Algorithm {
+	case SlidingWindow, "":
+		return NewLimiter(cfg.Rate, cfg.Limit), nil
+	case TokenBucket:
+		return NewTokenBucket(cfg.Rate, cfg.Limit, cfg.Burst), nil
+	case GCRA:
+		return NewGCRA(cfg.Rate, cfg.Limit, cfg.Burst), nil
+	}
+
+	return nil, fmt.Errorf("unknown rate limiting algorithm %q", cfg.Algorithm)
+}
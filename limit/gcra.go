@@ -0,0 +1,120 @@
+package limit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// gcra is generic cell rate algorithm, it keeps only theoretical arrival time of the next request
+type gcra struct {
+	sync.Mutex
+	interval  time.Duration // emission interval between requests at the steady rate
+	tolerance time.Duration // how much earlier than the theoretical arrival time requests are allowed
+	tat       time.Time
+}
+
+// NewGCRA spaces limit requests evenly each rate and lets through bursts up to burst requests,
+// burst is equal to limit if it's zero
+func NewGCRA(rate time.Duration, limit, burst int) Limiter {
+	if burst <= 0 {
+		burst = limit
+	}
+
+	interval := rate / time.Duration(limit)
+
+	return &gcra{
+		interval:  interval,
+		tolerance: interval * time.Duration(burst-1),
+	}
+}
+
+func (g *gcra) Allow() bool {
+	return reserveFunc(g.reserve).allowN(1)
+}
+
+func (g *gcra) AllowN(n int) bool {
+	return reserveFunc(g.reserve).allowN(n)
+}
+
+func (g *gcra) Reserve() time.Duration {
+	return reserveFunc(g.reserve).reserve()
+}
+
+func (g *gcra) ReserveN(n int, max time.Duration) (time.Duration, bool) {
+	return reserveFunc(g.reserve).reserveN(n, max)
+}
+
+func (g *gcra) Wait(ctx context.Context) error {
+	return reserveFunc(g.reserve).wait(ctx, g.cancel)
+}
+
+// reserve moves theoretical arrival time by n intervals, requests are admitted when it's within tolerance
+func (g *gcra) reserve(n int64, max time.Duration) (time.Duration, bool) {
+	g.Lock()
+	defer g.Unlock()
+
+	// more requests than the burst are out of tolerance even for the idle limiter
+	if g.interval*time.Duration(n) > g.tolerance+g.interval {
+		return never, false
+	}
+
+	now := now()
+
+	tat := g.tat
+	if tat.Before(now) {
+		tat = now
+	}
+
+	next := tat.Add(g.interval * time.Duration(n))
+
+	delay := next.Add(-g.tolerance - g.interval).Sub(now)
+	if delay < 0 {
+		delay = 0
+	}
+
+	if delay > max {
+		return delay, false
+	}
+
+	g.tat = next
+
+	return delay, true
+}
+
+// cancel moves theoretical arrival time back, it isn't earlier than now to not grant extra burst
+func (g *gcra) cancel(n int64) {
+	g.Lock()
+	defer g.Unlock()
+
+	g.tat = g.tat.Add(-g.interval * time.Duration(n))
+	if now := now(); g.tat.Before(now) {
+		g.tat = now
+	}
+}
+
+func (g *gcra) State() State {
+	g.Lock()
+	defer g.Unlock()
+
+	now := now()
+
+	var state = State{Limit: int((g.tolerance + g.interval) / g.interval)}
+
+	if g.tat.After(now) {
+		state.Reset = g.tat.Sub(now)
+	}
+
+	// each admitted request moves theoretical arrival time by the interval until it's out of tolerance
+	// reserved requests move it further than the tolerance, then nothing remains
+	state.Remaining = int((g.tolerance + g.interval - state.Reset) / g.interval)
+	if state.Remaining < 0 {
+		state.Remaining = 0
+	}
+
+	if wait := state.Reset - g.tolerance; wait > 0 {
+		state.RetryAfter = wait
+	}
+
+	return state
+}
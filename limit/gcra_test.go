@@ -0,0 +1,112 @@
+package limit
+
+import (
+	"testing"
+	"time"
+)
+
+func Test_gcra_Allow(t *testing.T) {
+	tests := []struct {
+		name  string
+		limit int
+		burst int
+		steps []step
+	}{
+		{
+			name:  "burst at once",
+			limit: 10,
+			burst: 3,
+			steps: []step{
+				{at: 0, n: 5, want: 3},
+			},
+		},
+		{
+			name:  "burst is equal to limit by default",
+			limit: 10,
+			steps: []step{
+				{at: 0, n: 12, want: 10},
+			},
+		},
+		{
+			name:  "no burst spaces requests evenly",
+			limit: 10,
+			burst: 1,
+			steps: []step{
+				{at: 0, n: 2, want: 1},
+				{at: time.Millisecond * 50, n: 1, want: 0},
+				{at: time.Millisecond * 100, n: 2, want: 1},
+				{at: time.Millisecond * 250, n: 2, want: 1},
+			},
+		},
+		{
+			name:  "burst is restored at the rate",
+			limit: 10,
+			burst: 3,
+			steps: []step{
+				{at: 0, n: 3, want: 3},
+				{at: time.Millisecond * 100, n: 2, want: 1},
+				{at: time.Millisecond * 300, n: 3, want: 2},
+				{at: time.Second, n: 5, want: 3},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func() { now = time.Now }()
+			runSteps(t, NewGCRA(time.Second, tt.limit, tt.burst), tt.steps)
+		})
+	}
+}
+
+func Test_gcra_Reserve(t *testing.T) {
+	defer func() { now = time.Now }()
+	runReservations(t, NewGCRA(time.Second, 10, 2), burstReservations)
+}
+
+func Test_gcra_State(t *testing.T) {
+	tests := []struct {
+		name     string
+		at       time.Duration // offset from the burst of 5 requests
+		reserved int           // requests reserved after the burst
+		want     State
+	}{
+		{
+			name: "exhausted burst",
+			want: State{Limit: 5, Remaining: 0, Reset: time.Millisecond * 500, RetryAfter: time.Millisecond * 100},
+		},
+		{
+			name:     "requests reserved over the burst",
+			reserved: 2,
+			want:     State{Limit: 5, Remaining: 0, Reset: time.Millisecond * 700, RetryAfter: time.Millisecond * 300},
+		},
+		{
+			name: "partially restored burst",
+			at:   time.Millisecond * 150,
+			want: State{Limit: 5, Remaining: 1, Reset: time.Millisecond * 350},
+		},
+		{
+			name: "fully restored burst",
+			at:   time.Second,
+			want: State{Limit: 5, Remaining: 5},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func() { now = time.Now }()
+
+			var start = time.Unix(0, time.Second.Nanoseconds())
+			now = func() time.Time { return start }
+
+			l := NewGCRA(time.Second, 10, 5)
+			l.AllowN(5)
+			for i := 0; i < tt.reserved; i++ {
+				l.Reserve()
+			}
+
+			now = func() time.Time { return start.Add(tt.at) }
+			if got := l.State(); got != tt.want {
+				t.Errorf("State() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -1,5 +1,24 @@
 package limit
 
+import (
+	"context"
+	"time"
+)
+
 type Limiter interface {
 	Allow() bool
+	AllowN(n int) bool
+	Reserve() time.Duration // reserves a request and tells how long to wait until it's admitted
+	// ReserveN reserves n requests unless they have to wait longer than max, the delay is told either way
+	ReserveN(n int, max time.Duration) (time.Duration, bool)
+	Wait(ctx context.Context) error
+	State() State
+}
+
+// State is the quota of the limiter at the moment
+type State struct {
+	Limit      int           // requests in the quota
+	Remaining  int           // requests which are admitted right now
+	Reset      time.Duration // time until the quota is fully restored
+	RetryAfter time.Duration // time until the next request is admitted
 }
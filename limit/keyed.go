@@ -0,0 +1,122 @@
+package limit
+
+import (
+	"container/list"
+	"hash/fnv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const shardsCount = 32
+
+type KeyedLimiter interface {
+	Allow(key string) bool
+	Limiter(key string) Limiter // limiter of the key, it's made if there is none
+}
+
+type entry struct {
+	key      string
+	limiter  Limiter
+	lastSeen time.Time
+}
+
+type shard struct {
+	sync.Mutex
+	entries map[string]*list.Element
+	lru     *list.List // the least recently used key is at the back
+}
+
+type keyedLimiter struct {
+	newLimiter func() Limiter
+	ttl        time.Duration // idle keys are evicted after it
+	maxKeys    int           // per shard, the least recently used key is evicted above it
+	shards     [shardsCount]*shard
+	cursor     uint32 // the shard to sweep
+}
+
+// NewKeyedLimiter limits each key by its own limiter made by newLimiter,
+// keys idle longer than ttl are evicted as well as the least recently used ones above maxKeys
+func NewKeyedLimiter(newLimiter func() Limiter, ttl time.Duration, maxKeys int) KeyedLimiter {
+	l := &keyedLimiter{
+		newLimiter: newLimiter,
+		ttl:        ttl,
+		maxKeys:    (maxKeys + shardsCount - 1) / shardsCount,
+	}
+
+	for i := range l.shards {
+		l.shards[i] = &shard{entries: make(map[string]*list.Element), lru: list.New()}
+	}
+
+	return l
+}
+
+func (l *keyedLimiter) Allow(key string) bool {
+	return l.Limiter(key).Allow()
+}
+
+func (l *keyedLimiter) Limiter(key string) Limiter {
+	l.sweep()
+	return l.get(key)
+}
+
+// sweep evicts idle keys of shards in turn, so keys which are never used again don't stay forever
+func (l *keyedLimiter) sweep() {
+	s := l.shards[atomic.AddUint32(&l.cursor, 1)%shardsCount]
+
+	s.Lock()
+	defer s.Unlock()
+
+	l.evict(s, now())
+}
+
+func (l *keyedLimiter) get(key string) Limiter {
+	s := l.shard(key)
+	now := now()
+
+	s.Lock()
+	defer s.Unlock()
+
+	l.evict(s, now)
+
+	if elem, ok := s.entries[key]; ok {
+		e := elem.Value.(*entry)
+		e.lastSeen = now
+		s.lru.MoveToFront(elem)
+		return e.limiter
+	}
+
+	if l.maxKeys > 0 && s.lru.Len() >= l.maxKeys {
+		l.remove(s, s.lru.Back())
+	}
+
+	e := &entry{key: key, limiter: l.newLimiter(), lastSeen: now}
+	s.entries[key] = s.lru.PushFront(e)
+
+	return e.limiter
+}
+
+// evict removes keys idle longer than ttl, they are at the back of the lru list
+func (l *keyedLimiter) evict(s *shard, now time.Time) {
+	if l.ttl <= 0 {
+		return
+	}
+
+	for elem := s.lru.Back(); elem != nil; elem = s.lru.Back() {
+		if now.Sub(elem.Value.(*entry).lastSeen) < l.ttl {
+			return
+		}
+		l.remove(s, elem)
+	}
+}
+
+func (l *keyedLimiter) remove(s *shard, elem *list.Element) {
+	delete(s.entries, elem.Value.(*entry).key)
+	s.lru.Remove(elem)
+}
+
+func (l *keyedLimiter) shard(key string) *shard {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return l.shards[h.Sum32()%shardsCount]
+}
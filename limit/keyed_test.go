@@ -0,0 +1,110 @@
+package limit
+
+import (
+	"testing"
+	"time"
+)
+
+type call struct {
+	at   time.Duration // offset from the start
+	key  string
+	want bool
+}
+
+func Test_keyedLimiter_Allow(t *testing.T) {
+	tests := []struct {
+		name    string
+		limit   int // per key in a second
+		ttl     time.Duration
+		maxKeys int
+		calls   []call
+		wantLen int // number of stored keys after the calls
+	}{
+		{
+			name:    "keys are limited separately",
+			limit:   2,
+			ttl:     time.Minute,
+			maxKeys: 100,
+			calls: []call{
+				{key: "a", want: true},
+				{key: "a", want: true},
+				{key: "a", want: false},
+				{key: "b", want: true},
+				{key: "b", want: true},
+				{key: "b", want: false},
+			},
+			wantLen: 2,
+		},
+		{
+			name:    "idle keys are evicted",
+			limit:   100,
+			ttl:     time.Second * 5,
+			maxKeys: 100,
+			calls: append([]call{
+				{key: "a", want: true},
+				{at: time.Second * 4, key: "b", want: true},
+			}, repeat(call{at: time.Second * 6, key: "c", want: true}, shardsCount)...), // every shard is swept
+			wantLen: 2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func() { now = time.Now }()
+
+			var start = time.Unix(0, time.Second.Nanoseconds())
+
+			l := NewKeyedLimiter(func() Limiter { return NewLimiter(time.Second, tt.limit) }, tt.ttl, tt.maxKeys).(*keyedLimiter)
+
+			for i, c := range tt.calls {
+				now = func() time.Time { return start.Add(c.at) }
+				if got := l.Allow(c.key); got != c.want {
+					t.Errorf("call #%d: Allow(%q) = %v, want %v", i, c.key, got, c.want)
+				}
+			}
+
+			var keys int
+			for _, s := range l.shards {
+				keys += len(s.entries)
+			}
+			if keys != tt.wantLen {
+				t.Errorf("Allow() kept %d keys, want %d", keys, tt.wantLen)
+			}
+		})
+	}
+}
+
+func repeat(c call, n int) []call {
+	var calls = make([]call, n)
+	for i := range calls {
+		calls[i] = c
+	}
+	return calls
+}
+
+func Test_keyedLimiter_lru(t *testing.T) {
+	l := NewKeyedLimiter(func() Limiter { return NewLimiter(time.Second, 1) }, time.Minute, shardsCount*2).(*keyedLimiter)
+
+	// keys of the same shard, which keeps two keys
+	var keys []string
+	for i := 0; len(keys) < 3; i++ {
+		key := string(rune('a'+i%26)) + string(rune('a'+i/26))
+		if l.shard(key) == l.shard("aa") {
+			keys = append(keys, key)
+		}
+	}
+
+	l.Allow(keys[0])
+	l.Allow(keys[1])
+	l.Allow(keys[0]) // the second key is the least recently used now
+	l.Allow(keys[2])
+
+	s := l.shard(keys[0])
+	if _, ok := s.entries[keys[1]]; ok || len(s.entries) != 2 {
+		t.Errorf("Allow() kept keys %v, want %v evicted", s.entries, keys[1])
+	}
+
+	// the first key is still limited, since it wasn't evicted
+	if l.Allow(keys[0]) {
+		t.Errorf("Allow(%q) = true, want false", keys[0])
+	}
+}
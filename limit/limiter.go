@@ -1,6 +1,9 @@
 package limit
 
 import (
+	"context"
+	"math"
+	"sync"
 	"sync/atomic"
 	"time"
 )
@@ -30,6 +33,8 @@ func (w *window) set(start, num int64) {
 }
 
 type limiter struct {
+	sync.Mutex // makes check and reservation atomic
+
 	rate  int64
 	limit int64
 	curr  *window
@@ -71,7 +76,10 @@ func (l *limiter) renew(now time.Time) {
 }
 
 func (l *limiter) count() int64 {
-	now := now()
+	return l.countAt(now())
+}
+
+func (l *limiter) countAt(now time.Time) int64 {
 
 	// try to renew windows by dynamically move it forward and swap values
 	l.renew(now)
@@ -86,12 +94,115 @@ func (l *limiter) count() int64 {
 	return int64(weight*float64(l.prev.num())) + l.curr.num()
 }
 
+// delay is the time until the approximated count leaves room for n requests,
+// weight of previous window decreases linearly, so the offset where it's small enough is found from the formula
+func (l *limiter) delay(now time.Time, n int64) time.Duration {
+	if l.countAt(now)+n <= l.limit {
+		return 0
+	}
+
+	offset := now.UnixNano() - l.curr.start()
+	prev, curr := l.prev.num(), l.curr.num()
+
+	// current window is already full, so wait for the next one where the current window becomes previous
+	if l.limit-n-curr < 0 {
+		if l.limit-n < 0 {
+			return never
+		}
+		return time.Duration(l.rate-offset) + l.offsetFor(curr, l.limit-n)
+	}
+
+	if delay := l.offsetFor(prev, l.limit-n-curr) - time.Duration(offset); delay > 0 {
+		return delay
+	}
+	return 1 // rounding of the formula, the room is right ahead
+}
+
+// offsetFor is the window offset where int64(weight*prev) <= room
+func (l *limiter) offsetFor(prev, room int64) time.Duration {
+	if prev <= room {
+		return 0
+	}
+	// weight*prev < room+1, where weight = (rate-offset)/rate
+	return time.Duration(math.Floor(float64(l.rate)*(1-float64(room+1)/float64(prev)))) + 1
+}
+
+func (l *limiter) reserve(n int64, max time.Duration) (time.Duration, bool) {
+	l.Lock()
+	defer l.Unlock()
+
+	delay := l.delay(now(), n)
+	if delay > max {
+		return delay, false
+	}
+
+	// requests admitted later are counted in the current window, it's stricter than needed
+	l.curr.incr(n)
+
+	return delay, true
+}
+
+// cancel uncounts requests from the current window, or from the previous one if they were reserved before it ended
+func (l *limiter) cancel(n int64) {
+	l.Lock()
+	defer l.Unlock()
+
+	l.renew(now())
+
+	for _, w := range []*window{l.curr, l.prev} {
+		take := n
+		if num := w.num(); take > num {
+			take = num
+		}
+		w.incr(-take)
+		n -= take
+	}
+}
+
 func (l *limiter) Allow() bool {
-	if l.count() >= l.limit {
-		return false
+	return reserveFunc(l.reserve).allowN(1)
+}
+
+func (l *limiter) AllowN(n int) bool {
+	return reserveFunc(l.reserve).allowN(n)
+}
+
+func (l *limiter) Reserve() time.Duration {
+	return reserveFunc(l.reserve).reserve()
+}
+
+func (l *limiter) ReserveN(n int, max time.Duration) (time.Duration, bool) {
+	return reserveFunc(l.reserve).reserveN(n, max)
+}
+
+func (l *limiter) Wait(ctx context.Context) error {
+	return reserveFunc(l.reserve).wait(ctx, l.cancel)
+}
+
+func (l *limiter) State() State {
+	l.Lock()
+	defer l.Unlock()
+
+	now := now()
+
+	remaining := l.limit - l.countAt(now)
+	if remaining < 0 {
+		remaining = 0
 	}
 
-	l.curr.incr(1)
+	// previous window is forgotten when the current one is over, the current one is forgotten a window later
+	var reset time.Duration
+	switch {
+	case l.curr.num() > 0:
+		reset = time.Duration(2*l.rate - (now.UnixNano() - l.curr.start()))
+	case l.prev.num() > 0:
+		reset = time.Duration(l.rate - (now.UnixNano() - l.curr.start()))
+	}
 
-	return true
+	return State{
+		Limit:      int(l.limit),
+		Remaining:  int(remaining),
+		Reset:      reset,
+		RetryAfter: l.delay(now, 1),
+	}
 }
@@ -1,6 +1,7 @@
 package limit
 
 import (
+	"context"
 	"testing"
 	"time"
 )
@@ -215,3 +216,167 @@ func Test_limiter_count(t *testing.T) {
 		})
 	}
 }
+
+func Test_limiter_delay(t *testing.T) {
+	type fields struct {
+		curr *window
+		prev *window
+	}
+
+	tests := []struct {
+		name   string
+		fields fields
+		offset time.Duration // of the current window
+		n      int64
+		want   time.Duration
+	}{
+		{
+			name:   "room for requests",
+			fields: fields{curr: &window{n: 4}, prev: &window{n: 5}},
+			n:      1,
+			want:   0,
+		},
+		{
+			name:   "previous window leaves room right ahead",
+			fields: fields{curr: &window{}, prev: &window{n: 10}},
+			n:      1,
+			want:   1, // 10 * (1000000000 - 1)/1000000000 + 0 = 9
+		},
+		{
+			name:   "weight of previous window should drop by half",
+			fields: fields{curr: &window{n: 5}, prev: &window{n: 10}},
+			n:      1,
+			want:   time.Millisecond*500 + 1, // 10 * (1000000000 - 500000001)/1000000000 + 5 = 9
+		},
+		{
+			name:   "weight of previous window is partially dropped",
+			fields: fields{curr: &window{n: 5}, prev: &window{n: 10}},
+			offset: time.Millisecond * 300,
+			n:      1,
+			want:   time.Millisecond*200 + 1,
+		},
+		{
+			name:   "many requests wait longer",
+			fields: fields{curr: &window{n: 5}, prev: &window{n: 10}},
+			offset: time.Millisecond * 300,
+			n:      3,
+			want:   time.Millisecond*400 + 1, // 10 * (1000000000 - 700000001)/1000000000 + 5 = 7
+		},
+		{
+			name:   "full current window waits for the next one",
+			fields: fields{curr: &window{n: 10}, prev: &window{}},
+			offset: time.Millisecond * 300,
+			n:      1,
+			want:   time.Millisecond*700 + 1, // 10 * (1000000000 - 1)/1000000000 + 0 = 9
+		},
+		{
+			name:   "more requests than limit are never admitted",
+			fields: fields{curr: &window{}, prev: &window{}},
+			n:      11,
+			want:   never,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var start = time.Second.Nanoseconds()
+
+			tt.fields.curr.s = start
+			tt.fields.prev.s = start - time.Second.Nanoseconds()
+
+			l := &limiter{
+				rate:  time.Second.Nanoseconds(),
+				limit: 10,
+				curr:  tt.fields.curr,
+				prev:  tt.fields.prev,
+			}
+			if got := l.delay(time.Unix(0, start).Add(tt.offset), tt.n); got != tt.want {
+				t.Errorf("delay() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_limiter_Wait(t *testing.T) {
+	now = time.Now // it's mocked by other tests
+
+	tests := []struct {
+		name    string
+		rate    time.Duration
+		timeout time.Duration
+		wantErr error
+	}{
+		{
+			name:    "request is admitted in time",
+			rate:    time.Millisecond * 100,
+			timeout: time.Second,
+		},
+		{
+			name:    "admission is after the deadline",
+			rate:    time.Hour,
+			timeout: time.Millisecond * 10,
+			wantErr: ErrWaitTooLong,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := NewLimiter(tt.rate, 1)
+
+			if !l.Allow() {
+				t.Fatal("Allow() of empty limiter = false")
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), tt.timeout)
+			defer cancel()
+
+			if err := l.Wait(ctx); err != tt.wantErr {
+				t.Errorf("Wait() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func Test_limiter_State(t *testing.T) {
+	tests := []struct {
+		name   string
+		curr   int64
+		prev   int64
+		offset time.Duration // of the current window
+		want   State
+	}{
+		{
+			name: "empty windows",
+			want: State{Limit: 10, Remaining: 10},
+		},
+		{
+			name:   "previous window fades out",
+			prev:   4,
+			offset: time.Millisecond * 500,
+			want:   State{Limit: 10, Remaining: 8, Reset: time.Millisecond * 500},
+		},
+		{
+			name:   "exhausted quota",
+			curr:   5,
+			prev:   10,
+			offset: time.Millisecond * 300,
+			want:   State{Limit: 10, Remaining: 0, Reset: time.Millisecond * 1700, RetryAfter: time.Millisecond*200 + 1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func() { now = time.Now }()
+
+			var start = time.Second.Nanoseconds()
+			now = func() time.Time { return time.Unix(0, start).Add(tt.offset) }
+
+			l := &limiter{
+				rate:  time.Second.Nanoseconds(),
+				limit: 10,
+				curr:  &window{s: start, n: tt.curr},
+				prev:  &window{s: start - time.Second.Nanoseconds(), n: tt.prev},
+			}
+			if got := l.State(); got != tt.want {
+				t.Errorf("State() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,64 @@
+package limit
+
+import (
+	"context"
+	"errors"
+	"math"
+	"time"
+)
+
+// never is the delay of requests which can't be admitted at all
+const never = time.Duration(math.MaxInt64)
+
+var ErrWaitTooLong = errors.New("rate limit: admission is later than the context deadline")
+
+// reserveFunc reserves n requests unless they have to wait longer than max, it returns the delay until admission
+type reserveFunc func(n int64, max time.Duration) (time.Duration, bool)
+
+func (reserve reserveFunc) allowN(n int) bool {
+	_, ok := reserve(int64(n), 0)
+	return ok
+}
+
+func (reserve reserveFunc) reserveN(n int, max time.Duration) (time.Duration, bool) {
+	return reserve(int64(n), max)
+}
+
+// cancelFunc gives back n requests which were reserved but weren't admitted
+type cancelFunc func(n int64)
+
+func (reserve reserveFunc) reserve() time.Duration {
+	delay, _ := reserve(1, never-1)
+	return delay
+}
+
+// wait blocks until the reserved request is admitted, the reservation is given back if the context ends earlier
+func (reserve reserveFunc) wait(ctx context.Context, cancel cancelFunc) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	var max = never - 1
+	if deadline, ok := ctx.Deadline(); ok {
+		max = deadline.Sub(now())
+	}
+
+	delay, ok := reserve(1, max)
+	if !ok {
+		return ErrWaitTooLong
+	}
+	if delay <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		cancel(1)
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,54 @@
+package limit
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func Test_reserveFunc_wait_cancel(t *testing.T) {
+	tests := []struct {
+		name    string
+		limiter func() Limiter
+	}{
+		{
+			name:    "sliding window",
+			limiter: func() Limiter { return NewLimiter(time.Second, 1) },
+		},
+		{
+			name:    "token bucket",
+			limiter: func() Limiter { return NewTokenBucket(time.Second, 1, 1) },
+		},
+		{
+			name:    "gcra",
+			limiter: func() Limiter { return NewGCRA(time.Second, 1, 1) },
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func() { now = time.Now }()
+
+			var start = time.Unix(0, time.Second.Nanoseconds())
+			now = func() time.Time { return start }
+
+			// the delay of the next request when the canceled one isn't counted
+			twin := tt.limiter()
+			twin.Allow()
+			want := twin.Reserve()
+
+			l := tt.limiter()
+			l.Allow()
+
+			ctx, cancel := context.WithCancel(context.Background())
+			time.AfterFunc(time.Millisecond*10, cancel)
+
+			if err := l.Wait(ctx); err != context.Canceled {
+				t.Fatalf("Wait() error = %v, want %v", err, context.Canceled)
+			}
+
+			if got := l.Reserve(); got != want {
+				t.Errorf("Reserve() after canceled Wait() = %v, want %v", got, want)
+			}
+		})
+	}
+}
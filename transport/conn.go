@@ -0,0 +1,31 @@
+package transport
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"time"
+)
+
+type connKey struct{}
+
+type connInfo struct {
+	conn         net.Conn
+	writeTimeout time.Duration
+}
+
+func (s *server) connContext(ctx context.Context, conn net.Conn) context.Context {
+	return context.WithValue(ctx, connKey{}, connInfo{conn: conn, writeTimeout: s.WriteTimeout})
+}
+
+// ExtendWriteDeadline moves the connection write deadline one server write timeout forward,
+// long-living streaming handlers call it before each write to not be killed by WriteTimeout,
+// it does nothing for requests which aren't served by the server, they have no deadline of its own
+func ExtendWriteDeadline(r *http.Request) error {
+	info, ok := r.Context().Value(connKey{}).(connInfo)
+	if !ok || info.writeTimeout <= 0 {
+		return nil
+	}
+
+	return info.conn.SetWriteDeadline(time.Now().Add(info.writeTimeout))
+}
@@ -0,0 +1,81 @@
+package transport
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestExtendWriteDeadline(t *testing.T) {
+	tests := []struct {
+		name     string
+		extend   bool
+		wantBody string
+		wantErr  bool
+	}{
+		{
+			name:     "stream outlives write timeout",
+			extend:   true,
+			wantBody: "01234",
+		},
+		{
+			name:    "stream is cut by write timeout",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := NewServer("").(*server)
+			srv.WriteTimeout = time.Millisecond * 200
+
+			// the stream takes 500ms, more than the write timeout
+			srv.Get("/stream", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				for i := 0; i < 5; i++ {
+					if tt.extend {
+						if err := ExtendWriteDeadline(r); err != nil {
+							t.Errorf("ExtendWriteDeadline() error = %v", err)
+						}
+					}
+
+					fmt.Fprint(w, i)
+					w.(http.Flusher).Flush()
+					time.Sleep(time.Millisecond * 100)
+				}
+			}))
+
+			ln, err := net.Listen("tcp", "127.0.0.1:0")
+			if err != nil {
+				t.Fatal(err)
+			}
+			go srv.Serve(ln)
+			defer srv.Close()
+
+			resp, err := http.Get("http://" + ln.Addr().String() + "/stream")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+
+			body, err := ioutil.ReadAll(resp.Body)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("read body error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && string(body) != tt.wantBody {
+				t.Errorf("body = %q, want %q", body, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestExtendWriteDeadline_otherServer(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/stream", nil)
+
+	if err := ExtendWriteDeadline(r); err != nil {
+		t.Errorf("ExtendWriteDeadline() error = %v, want nil", err)
+	}
+}
@@ -16,7 +16,7 @@ func NewServer(address string) Server {
 
 	handlers := make(map[string]map[string]http.Handler)
 
-	return &server{
+	srv := &server{
 		Server: &http.Server{
 			Addr: address,
 
@@ -30,7 +30,7 @@ func NewServer(address string) Server {
 					return
 				}
 
-				handler, ok := byMethod[r.URL.String()]
+				handler, ok := byMethod[r.URL.Path]
 				if !ok {
 					http.NotFound(w, r)
 					return
@@ -41,6 +41,10 @@ func NewServer(address string) Server {
 		},
 		handlers: handlers,
 	}
+
+	srv.Server.ConnContext = srv.connContext
+
+	return srv
 }
 
 func (s *server) Start() error {